@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"net/netip"
+)
+
+// Способы обезличивания IP для --anonymize-ip
+var anonymizeModes = []string{"mask", "hash"}
+
+// Хеши для --ip-hash: keyed — ключевой криптографический, fast — быстрый некриптографический
+var ipHashModes = []string{"keyed", "fast"}
+
+// Сколько бит адреса сохраняется: сеть /24 для IPv4 и /48 для IPv6
+const (
+	anonymizeBitsV4 = 24
+	anonymizeBitsV6 = 48
+)
+
+// Обезличивание IP адресов (--anonymize-ip, --ip-hash). С --anonymize-ip сеть сохраняется,
+// чтобы записи одной подсети по-прежнему группировались вместе, а адрес клиента в сети скрывается:
+//
+//	mask: 192.168.1.77 → 192.168.1.0, 2001:db8:1:2::5 → 2001:db8:1::
+//	hash: 192.168.1.77 → 192.168.1.0#3fa9c1d2 (сеть и ключевой хеш полного адреса)
+//
+// С --ip-hash адрес целиком заменяется хешем: одинаковые клиенты группируются вместе,
+// но ни адреса, ни сети в отчете нет.
+//
+//	keyed: 192.168.1.77 → 5c1e0f3a9b2d7e48 (HMAC-SHA256 с ключом salt)
+//	fast:  192.168.1.77 → a1b2c3d4e5f60718 (FNV-1a, 64 бита)
+//
+// Ключевой хеш без ключа не подобрать: разные клиенты различимы, а адреса скрыты.
+// С одинаковым ключом результат одинаков между запусками. Быстрый хеш ничего не скрывает
+// (все адреса IPv4 перебираются за минуты) и нужен только для группировки.
+// Значения, которые не разбираются как IP, заменяются целиком (хешем или noneKey).
+type ipAnonymizer struct {
+	stage   string // описание для --explain, например "anonymize-ip(mask)"
+	network bool   // сохранять сеть адреса
+	hash    func(value string) string
+}
+
+// Новый обезличиватель для --anonymize-ip в режиме mode. Для hash без salt ключ
+// выбирается случайно: внутри запуска хеши согласованы, между запусками — нет.
+func newIPAnonymizer(mode, salt string) *ipAnonymizer {
+	a := &ipAnonymizer{stage: "anonymize-ip(" + mode + ")", network: true}
+	if mode == "hash" {
+		key := hashKey(salt)
+		a.hash = func(value string) string { return keyedDigest(key, value, 4) }
+	}
+	return a
+}
+
+// Новый обезличиватель для --ip-hash: адрес заменяется хешем kind (keyed или fast)
+func newIPHasher(kind, salt string) *ipAnonymizer {
+	a := &ipAnonymizer{stage: "ip-hash(" + kind + ")"}
+	if kind == "keyed" {
+		key := hashKey(salt)
+		a.hash = func(value string) string { return keyedDigest(key, value, 8) }
+	} else {
+		a.hash = fastDigest
+	}
+	return a
+}
+
+// Обезличенное значение ip
+func (a *ipAnonymizer) anonymize(ip string) string {
+	if !a.network {
+		return a.hash(ip)
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		if a.hash != nil {
+			return "#" + a.hash(ip)
+		}
+		return noneKey
+	}
+	bits := anonymizeBitsV6
+	if addr.Is4() || addr.Is4In6() {
+		addr = addr.Unmap()
+		bits = anonymizeBitsV4
+	}
+	network := netip.PrefixFrom(addr, bits).Masked().Addr().String()
+	if a.hash != nil {
+		return network + "#" + a.hash(ip)
+	}
+	return network
+}
+
+// Ключ HMAC: salt или, если он не задан, случайные 32 байта
+func hashKey(salt string) []byte {
+	if salt != "" {
+		return []byte(salt)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// Первые size байт HMAC-SHA256(key, value) в hex
+func keyedDigest(key []byte, value string, size int) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:size])
+}
+
+// FNV-1a (64 бита) от value в hex: быстро и одинаково между запусками, но без ключа
+func fastDigest(value string) string {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Стадия pipeline: заменяем IP каждой записи обезличенным
+func anonymizeIPs(input <-chan LogEntry, a *ipAnonymizer) <-chan LogEntry {
+	return transformLogs(input, func(logEntry LogEntry) LogEntry {
+		logEntry.IP = a.anonymize(logEntry.IP)
+		return logEntry
+	})
+}
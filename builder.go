@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+)
+
+// Стадия pipeline: принимает канал записей и возвращает канал результата
+type pipelineStage func(ctx context.Context, input <-chan LogEntry) <-chan LogEntry
+
+// Конструктор pipeline из стадий runPipeline для сценариев, которых нет среди флагов:
+//
+//	stats, err := NewPipeline(r).Workers(4).Filter(statusAtLeast(500)).Transform(fn).Collect(ctx)
+//
+// Методы только запоминают стадии и возвращают тот же Pipeline; чтение и обработка
+// начинаются в Collect. Стадии выполняются в порядке добавления. Как и Analyze,
+// конструктор доступен только внутри пакета main: импортировать его нельзя.
+type Pipeline struct {
+	r      io.Reader
+	opts   Options
+	stages []pipelineStage
+}
+
+// Новый pipeline для чтения логов из r с настройками по умолчанию
+func NewPipeline(r io.Reader) *Pipeline {
+	return &Pipeline{r: r, opts: defaultOptions()}
+}
+
+// Настройки чтения и подсчета статистики (схема, выбранные показатели и т.д.).
+// Стадии, которые runPipeline включает по настройкам (--only, --since и другие),
+// здесь не добавляются: их задают методами конструктора. Незаполненные поля
+// берутся из настроек по умолчанию (withDefaults).
+func (p *Pipeline) WithOptions(opts Options) *Pipeline {
+	p.opts = withDefaults(opts)
+	return p
+}
+
+// Обработка пулом из n воркеров (processLogs); порядок записей может измениться
+func (p *Pipeline) Workers(n int) *Pipeline {
+	return p.add(func(ctx context.Context, input <-chan LogEntry) <-chan LogEntry {
+		return processLogs(ctx, input, n, nil, p.opts.PreserveOrder)
+	})
+}
+
+// Оставляем только записи, для которых match возвращает true (filterLogs)
+func (p *Pipeline) Filter(match logPredicate) *Pipeline {
+	return p.add(func(_ context.Context, input <-chan LogEntry) <-chan LogEntry {
+		return filterLogs(input, match)
+	})
+}
+
+// Заменяем каждую запись результатом fn (transformLogs)
+func (p *Pipeline) Transform(fn func(LogEntry) LogEntry) *Pipeline {
+	return p.add(func(_ context.Context, input <-chan LogEntry) <-chan LogEntry {
+		return transformLogs(input, fn)
+	})
+}
+
+// Произвольная стадия (например, обертка над normalizeMethods или decodeURLs)
+func (p *Pipeline) Stage(stage pipelineStage) *Pipeline {
+	return p.add(stage)
+}
+
+func (p *Pipeline) add(stage pipelineStage) *Pipeline {
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Запускаем pipeline: чтение → стадии → подсчет статистики. Как и runPipeline,
+// при отмене контекста возвращает частичную статистику и ошибку контекста.
+func (p *Pipeline) Collect(ctx context.Context) (Statistics, error) {
+	var skipped skippedLines
+	logChan, err := readLogs(ctx, p.r, p.opts, &skipped)
+	if err != nil {
+		return Statistics{}, err
+	}
+	for _, stage := range p.stages {
+		logChan = stage(ctx, logChan)
+	}
+
+	stats := calculateStatsSharded(logChan, p.opts, p.opts.StatsShards)
+	stats.SkippedLines = skipped
+	return stats, ctx.Err()
+}
+
+// Подсчет статистики по записям, которые уже есть в памяти (например, получены
+// из другого источника внутри модуля): без каналов, горутин и разбора. Накопитель тот же,
+// что у calculateStats, поэтому результат совпадает с обработкой тех же записей через pipeline.
+// Для больших объемов и чтения из файлов — runPipeline или NewPipeline. Незаполненные
+// поля opts берутся из настроек по умолчанию (withDefaults); как и Analyze, функция
+// доступна только внутри пакета main.
+func CalculateStatsSlice(entries []LogEntry, opts Options) Statistics {
+	acc := newStatsAccumulator(withDefaults(opts))
+	for _, logEntry := range entries {
+		acc.Add(logEntry)
+	}
+	return acc.Result()
+}
@@ -0,0 +1,734 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Настройки подкоманды: значения флагов и входные файлы. Каждая подкоманда
+// регистрирует только нужные ей группы флагов, остальные поля остаются нулевыми.
+type cliConfig struct {
+	flags     *flag.FlagSet
+	opts      Options
+	inputOpts InputOptions
+
+	// Входные данные
+	multiline   bool
+	inputFiles  []string
+	filesFrom   string
+	kafkaTopic  string
+	socketPath  string
+	lastNFiles  int
+	schemaFile  string
+	inputFormat string
+	fieldWidths string
+	rejectsFile string
+
+	// Запуск
+	progress   bool
+	timeout    time.Duration
+	explain    bool
+	cpuProfile string
+	memProfile string
+
+	// Завершает вывод --progress (nil — прогресс не выводится)
+	stopProgress func()
+
+	// Время начала обработки для --timing
+	timing    bool
+	startedAt time.Time
+
+	// Сколько ждать новых записей, прежде чем сообщить о зависании (0 — не следить)
+	stallTimeout time.Duration
+
+	// Ждать дописанных в файл строк после конца файла (--follow)
+	follow bool
+
+	// Обработка и отчет
+	anonymizeIP       string
+	anonymizeSalt     string
+	ipHash            string
+	countOnly         bool
+	summaryOnly       bool
+	noNormalizeMethod bool
+	urlPatternNegated bool // --url-pattern задан с префиксом "!" (как --url-pattern-invert)
+	dump              bool
+	format            string
+	resolveDNS        bool
+	tui               bool
+	locale            string
+	openMetricsOut    string
+	heatmapOut        string
+	errorLogFile      string
+	sloConfigFile     string
+	stateFile         string
+	saveStatsFile     string
+	compareFile       string
+	statusAddr        string
+}
+
+// Новые настройки подкоманды name со значениями по умолчанию
+func newCLIConfig(name string) *cliConfig {
+	return &cliConfig{
+		flags:       flag.NewFlagSet(name, flag.ExitOnError),
+		opts:        defaultOptions(),
+		inputOpts:   defaultInputOptions(),
+		inputFormat: "csv",
+		format:      "text",
+	}
+}
+
+// Флаги входных данных: источники, схема, формат строк
+func (cfg *cliConfig) addInputFlags() {
+	fs, opts, inputOpts := cfg.flags, &cfg.opts, &cfg.inputOpts
+
+	fs.StringVar(&cfg.rejectsFile, "rejects", "", "файл для записи нераспознанных строк")
+	fs.IntVar(&inputOpts.HTTPRetries, "http-retries", inputOpts.HTTPRetries, "количество повторов при ошибках загрузки по HTTP")
+	fs.DurationVar(&inputOpts.HTTPBackoff, "http-backoff", inputOpts.HTTPBackoff, "пауза перед первым повтором загрузки по HTTP (далее удваивается)")
+	fs.Func("time-unit", "единица времени ответа во входных данных: ms, us, s (по умолчанию ms)", func(value string) error {
+		unit, err := parseTimeUnit(value)
+		opts.TimeUnit = unit
+		return err
+	})
+	fs.Func("kafka-brokers", "адреса брокеров Kafka через запятую", func(value string) error {
+		inputOpts.KafkaBrokers = strings.Split(value, ",")
+		return nil
+	})
+	fs.StringVar(&cfg.kafkaTopic, "kafka-topic", "", "читать логи из топика Kafka (до отмены, например по Ctrl+C или --timeout)")
+	fs.StringVar(&inputOpts.KafkaGroup, "kafka-group", inputOpts.KafkaGroup, "группа потребителей Kafka")
+	fs.DurationVar(&inputOpts.KafkaCommitInterval, "kafka-commit-interval", inputOpts.KafkaCommitInterval, "как часто коммитить смещения Kafka")
+	fs.StringVar(&cfg.socketPath, "socket", "", "читать логи из Unix сокета (до закрытия соединения отправителем или отмены)")
+	fs.Func("socket-mode", "как открыть --socket: connect (подключиться к сокету, по умолчанию) или listen (создать сокет и ждать подключения)", func(value string) error {
+		switch value {
+		case "connect":
+			inputOpts.SocketListen = false
+		case "listen":
+			inputOpts.SocketListen = true
+		default:
+			return fmt.Errorf("допустимые значения: connect, listen")
+		}
+		return nil
+	})
+	fs.StringVar(&cfg.schemaFile, "schema", "", "JSON файл со схемой колонок (вместо определения по заголовку)")
+	fs.BoolVar(&opts.AutoHeader, "auto-header", false, "считать первую строку данными, если она разбирается как запись (иначе — заголовок)")
+	fs.BoolVar(&opts.PrintSchema, "print-schema", false, "вывести в stderr, какие колонки каким полям сопоставлены, и продолжить обработку")
+	fs.BoolVar(&opts.NoTrim, "no-trim", false, "не убирать пробелы по краям значений колонок (по умолчанию \" GET \" читается как GET)")
+	fs.BoolVar(&cfg.multiline, "multiline", false, "собирать многострочные записи: строки, которые не начинаются со времени записи, продолжают предыдущую")
+	fs.Func("record-start", "регулярное выражение для начала записи с --multiline (по умолчанию — время в начале строки)", func(value string) error {
+		pattern, err := regexp.Compile(value)
+		opts.RecordStart = pattern
+		return err
+	})
+	fs.BoolVar(&opts.AllowExtraFields, "allow-extra-fields", false, "допускать в строках лишние колонки после описанных в схеме (они отбрасываются)")
+	fs.StringVar(&cfg.inputFormat, "input-format", cfg.inputFormat, "формат входных строк: csv (колонки через разделитель) или fixed (колонки фиксированной ширины)")
+	fs.StringVar(&cfg.fieldWidths, "field-widths", "", "ширины колонок в байтах для --input-format=fixed, например 19,15,6,30,3,6")
+	fs.Func("encoding", "кодировка входных данных: utf-8 (по умолчанию), windows-1251, koi8-r, iso-8859-1", func(value string) error {
+		enc, err := parseEncoding(value)
+		inputOpts.Encoding = enc
+		return err
+	})
+	fs.BoolVar(&cfg.follow, "follow", false, "после конца файла ждать новых строк, как tail -f (незаконченная последняя строка ждет перевода строки); остановка — Ctrl+C или --timeout")
+	fs.Func("read-rate", "ограничить скорость чтения входных данных, например 50MB/s (по умолчанию без ограничения)", func(value string) error {
+		bytesPerSecond, err := parseByteRate(value)
+		opts.ReadRate = bytesPerSecond
+		return err
+	})
+	fs.IntVar(&cfg.lastNFiles, "last-n-files", 0, "обрабатывать только N последних по времени изменения входных файлов (0 — все)")
+	fs.StringVar(&cfg.filesFrom, "files-from", "", "файл со списком входных файлов (по одному в строке)")
+}
+
+// Флаги запуска: ограничение времени, пул воркеров, профилирование
+func (cfg *cliConfig) addRunFlags() {
+	fs, opts := cfg.flags, &cfg.opts
+
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "максимальное время работы, например 30s (0 — без ограничения)")
+	fs.IntVar(&opts.Workers, "workers", opts.Workers, "количество воркеров в пуле обработки (по умолчанию — число CPU)")
+	fs.BoolVar(&cfg.timing, "timing", false, "вывести в stderr время обработки, количество строк и скорость (строк/с)")
+	fs.DurationVar(&cfg.stallTimeout, "stall-timeout", 0, "если за это время не обработано ни одной записи, вывести в stderr предупреждение и стеки горутин, например 1m (0 — не следить)")
+	fs.BoolVar(&cfg.progress, "progress", false, "выводить в stderr прочитанный объем, скорость и оставшееся время (для локальных файлов)")
+	fs.StringVar(&cfg.cpuProfile, "cpuprofile", "", "записать CPU профиль (runtime/pprof) в файл")
+	fs.StringVar(&cfg.memProfile, "memprofile", "", "записать профиль памяти (runtime/pprof) в файл после обработки")
+}
+
+// Флаги отбора записей: какие записи проходят дальше по pipeline и в выгрузку
+func (cfg *cliConfig) addFilterFlags() {
+	fs, opts := cfg.flags, &cfg.opts
+
+	fs.BoolVar(&cfg.noNormalizeMethod, "no-normalize-method", false, "не приводить HTTP метод к верхнему регистру")
+	fs.Func("anonymize-ip", "обезличивать IP до подсчета и выгрузки: mask (последний октет → 0) или hash (сеть и ключевой хеш адреса)", func(value string) error {
+		if !slices.Contains(anonymizeModes, value) {
+			return fmt.Errorf("допустимые значения: %s", strings.Join(anonymizeModes, ", "))
+		}
+		cfg.anonymizeIP = value
+		return nil
+	})
+	fs.Func("ip-hash", "заменять IP хешем до подсчета и выгрузки: keyed (ключевой HMAC-SHA256, скрывает адреса) или fast (FNV-1a, только для группировки)", func(value string) error {
+		if !slices.Contains(ipHashModes, value) {
+			return fmt.Errorf("допустимые значения: %s", strings.Join(ipHashModes, ", "))
+		}
+		cfg.ipHash = value
+		return nil
+	})
+	fs.StringVar(&cfg.anonymizeSalt, "anonymize-salt", "", "ключ хеша для --anonymize-ip=hash и --ip-hash=keyed (одинаковый ключ — одинаковые хеши между запусками; по умолчанию случайный)")
+	fs.BoolVar(&opts.DecodeURLs, "decode-urls", false, "раскодировать percent-encoding в URL перед подсчетом")
+	fs.IntVar(&opts.FilterMinStatus, "status-min", opts.FilterMinStatus, "фильтр для выгрузки: минимальный код ответа")
+	fs.StringVar(&opts.FilterMethod, "method", "", "фильтр для выгрузки: HTTP метод (например POST)")
+	fs.BoolVar(&opts.PreserveOrder, "preserve-order", false, "выгружать записи в исходном порядке (воркеры пула могут их переставлять)")
+	fs.Func("only", "обрабатывать только записи одного вида: success (2xx), redirects (3xx), errors", func(value string) error {
+		if !slices.Contains(onlyValues, value) {
+			return fmt.Errorf("допустимые значения: %s", strings.Join(onlyValues, ", "))
+		}
+		opts.Only = value
+		return nil
+	})
+	fs.Func("error-codes", "считать ошибками только коды ответа из списка, например 500,502,503,504 (вместо порога 400)", func(value string) error {
+		codes, err := parseErrorCodes(value)
+		opts.ErrorCodes = codes
+		return err
+	})
+	fs.Func("url-pattern", "обрабатывать только записи, URL которых соответствует регулярному выражению (с префиксом ! — не соответствует)", func(value string) error {
+		exclude := strings.HasPrefix(value, "!")
+		pattern, err := regexp.Compile(strings.TrimPrefix(value, "!"))
+		if err != nil {
+			return err
+		}
+		cfg.urlPatternNegated = exclude
+		if exclude {
+			opts.URLExclude = pattern
+		} else {
+			opts.URLPattern = pattern
+		}
+		return nil
+	})
+	fs.Func("url-pattern-invert", "пропускать записи, URL которых соответствует регулярному выражению", func(value string) error {
+		pattern, err := regexp.Compile(value)
+		opts.URLExclude = pattern
+		return err
+	})
+	fs.Func("min-response-time", "отбрасывать записи со временем ответа меньше границы, например 0ms (отрицательные значения от сбоя часов)", func(value string) error {
+		d, err := time.ParseDuration(value)
+		opts.MinResponseTime = durationMillis(d)
+		return err
+	})
+	fs.Func("max-response-time", "отбрасывать записи со временем ответа больше границы, например 10m", func(value string) error {
+		d, err := time.ParseDuration(value)
+		opts.MaxResponseTime = durationMillis(d)
+		return err
+	})
+	fs.DurationVar(&opts.Since, "since", 0, "обрабатывать только записи за последний период, например 1h (0 — все)")
+	fs.Func("relative-to", "от чего отсчитывать --since: max (самая поздняя запись, по умолчанию) или now (текущее время)", func(value string) error {
+		if !slices.Contains(relativeToValues, value) {
+			return fmt.Errorf("допустимые значения: %s", strings.Join(relativeToValues, ", "))
+		}
+		opts.RelativeTo = value
+		return nil
+	})
+	fs.IntVar(&opts.Head, "head", 0, "вывести первые N разобранных записей")
+	fs.IntVar(&opts.Tail, "tail", 0, "вывести последние N разобранных записей")
+	fs.BoolVar(&cfg.explain, "explain", false, "вывести стадии pipeline для текущих флагов и выйти без обработки")
+}
+
+// Флаги статистики и отчета
+func (cfg *cliConfig) addStatsFlags() {
+	fs, opts := cfg.flags, &cfg.opts
+
+	fs.IntVar(&opts.URLPrefixSegments, "group-by-prefix", 0, "группировать URL по первым N сегментам пути (0 — по полному пути)")
+	fs.StringVar(&opts.GroupByParam, "group-by-param", "", "считать запросы по значениям параметра query string (например version)")
+	fs.BoolVar(&opts.WorkerStats, "worker-stats", false, "вывести количество записей, обработанных каждым воркером")
+	fs.Func("stats", "вычислять только указанные показатели: total,errors,avg,topips,topurls,methods,endpoints,span,peak,classes,hours,cache (по умолчанию все, кроме hours)", func(value string) error {
+		selection, err := parseStatsSelection(value)
+		opts.Stats = selection
+		return err
+	})
+	fs.StringVar(&cfg.format, "format", cfg.format, "формат отчета: text (подробный, по умолчанию), compact (строки key: value на английском) или markdown (таблицы GitHub Markdown)")
+	fs.StringVar(&cfg.statusAddr, "status-addr", "", "адрес HTTP сервера с текущей статистикой в JSON по запросу GET /stats, например localhost:8080")
+	fs.BoolVar(&cfg.tui, "tui", false, "показывать в терминале счетчики, топ IP/URL и задержку по мере обработки")
+	fs.BoolVar(&cfg.dump, "dump", false, "выгрузить отфильтрованные записи (по умолчанию ошибки) в stdout в формате CSV")
+	fs.IntVar(&opts.TeeBufferSize, "tee-buffer", opts.TeeBufferSize, "размер буфера каждой ветви после tee (статистика и --dump)")
+	fs.BoolVar(&opts.TeeSpill, "tee-spill", false, "выгружать на диск записи, не поместившиеся в буфер отстающей ветви после tee")
+	fs.StringVar(&opts.TeeSpillDir, "tee-spill-dir", "", "каталог для файлов --tee-spill (по умолчанию временный каталог системы)")
+	fs.BoolVar(&opts.NoStats, "no-stats", false, "не считать статистику (вместе с --dump — режим чистого фильтра)")
+	fs.BoolVar(&cfg.countOnly, "count-only", false, "только посчитать корректные и нераспознанные строки (самый быстрый режим: без фильтров и статистики)")
+	fs.BoolVar(&cfg.summaryOnly, "summary-only", false, "выводить только итоговые значения (запросы, ошибки, время ответа), без рейтингов и таблиц; рейтинги не считаются")
+	fs.Func("top-by", "выводить вместо топ IP и топ URL один рейтинг: ip-requests, url-requests, url-errors, ip-bytes, url-latency, referer, user-agent", func(value string) error {
+		if !slices.Contains(topByValues, value) {
+			return fmt.Errorf("допустимые значения: %s", strings.Join(topByValues, ", "))
+		}
+		opts.TopBy = value
+		return nil
+	})
+	fs.BoolVar(&cfg.resolveDNS, "resolve-dns", false, "выводить рядом с IP из топ IP имя хоста по обратной записи DNS")
+	fs.IntVar(&opts.TopEndpoints, "top-endpoints", opts.TopEndpoints, "сколько эндпоинтов (метод + URL) выводить в рейтинге")
+	fs.DurationVar(&opts.SlowThreshold, "slow-threshold", 0, "считать медленными запросы дольше порога, например 500ms (0 — не считать)")
+	fs.DurationVar(&opts.FastThreshold, "fast-threshold", 0, "считать быстрыми запросы быстрее порога, например 50ms (0 — не считать)")
+	fs.DurationVar(&opts.GapInterval, "gap-interval", 0, "искать пропуски в логах: интервалы такой длины без запросов, например 5m (0 — не искать)")
+	fs.Func("status-granularity", "разбивка ответов по статусам: exact (по кодам, по умолчанию) или class (2xx, 4xx...)", func(value string) error {
+		if !slices.Contains(statusGranularities, value) {
+			return fmt.Errorf("допустимые значения: %s", strings.Join(statusGranularities, ", "))
+		}
+		opts.StatusByClass = value == "class"
+		return nil
+	})
+	fs.Float64Var(&opts.SpikeFactor, "spike-factor", 0, "искать всплески нагрузки: интервалы, где запросов больше скользящей медианы в k раз, например 3 (0 — не искать)")
+	fs.DurationVar(&opts.SpikeInterval, "spike-interval", opts.SpikeInterval, "длина интервала для --spike-factor")
+	fs.StringVar(&cfg.sloConfigFile, "slo-config", "", "YAML файл с целями SLO (шаблон URL, время ответа, перцентиль): вывести, выполнены ли они")
+	fs.Float64Var(&opts.AnomalySigma, "anomaly-sigma", 0, "сообщать о записях со временем ответа больше среднего + k·σ (например 3; 0 — не искать)")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "подробный отчет: разбивка топ URL по классам статусов (2xx, 4xx, 5xx)")
+	fs.StringVar(&opts.RollupDir, "rollup-dir", "", "каталог для почасовых сводок (<час>.json, обновляются по мере чтения)")
+	fs.DurationVar(&opts.RollupInterval, "rollup-interval", opts.RollupInterval, "как часто перезаписывать изменившиеся почасовые сводки")
+	fs.StringVar(&opts.SQLitePath, "sqlite", "", "загрузить записи в таблицу logs базы SQLite (файл создается, если его нет)")
+	fs.IntVar(&opts.SQLiteBatch, "sqlite-batch", opts.SQLiteBatch, "сколько записей вставлять в SQLite в одной транзакции")
+	fs.IntVar(&opts.StatsShards, "stats-shards", opts.StatsShards, "количество параллельных накопителей статистики (записи делятся по хешу IP)")
+	fs.StringVar(&thousandsSep, "thousands-sep", thousandsSep, "разделитель групп разрядов в количествах отчета (пусто — без разделителя)")
+	fs.StringVar(&cfg.locale, "locale", "", "разделитель групп разрядов по языку: en (1,234), de (1.234), ru и fr (1 234)")
+	fs.StringVar(&cfg.openMetricsOut, "openmetrics-out", "", "записать итоговую статистику в файл в формате OpenMetrics (для textfile collector)")
+	fs.StringVar(&cfg.heatmapOut, "heatmap-out", "", "записать количество запросов по дням недели и часам суток (7×24) в CSV для тепловой карты")
+	fs.StringVar(&cfg.stateFile, "state", "", "файл накопленной статистики: загрузить, добавить этот запуск и сохранить обратно")
+	fs.StringVar(&cfg.saveStatsFile, "save-stats", "", "сохранить статистику запуска в JSON файл (например, как базу для --compare)")
+	fs.StringVar(&cfg.compareFile, "compare", "", "сравнить статистику с сохраненной через --save-stats и вывести изменения")
+	fs.StringVar(&cfg.errorLogFile, "error-log", "", "журнал ошибок (CSV: timestamp,ip,message) для сопоставления с ответами 5xx")
+	fs.DurationVar(&opts.ErrorLogWindow, "error-log-window", opts.ErrorLogWindow, "наибольшая разница во времени между ответом 5xx и сообщением из журнала ошибок")
+}
+
+// Допустимые значения --status-granularity
+var statusGranularities = []string{"exact", "class"}
+
+// Флаги статистики и отчета, которые не имеют смысла с --no-stats
+var statsOnlyFlags = []string{
+	"stats", "format", "summary-only", "top-by", "resolve-dns", "top-endpoints", "group-by-prefix", "group-by-param",
+	"anomaly-sigma", "gap-interval", "spike-factor", "spike-interval", "status-granularity", "slow-threshold", "fast-threshold",
+	"verbose", "worker-stats", "stats-shards", "tui", "status-addr",
+	"state", "save-stats", "compare", "openmetrics-out", "heatmap-out", "error-log", "slo-config",
+}
+
+// Флаги отбора и выгрузки, которые не имеют смысла с --count-only (как и statsOnlyFlags):
+// записи только считаются
+var countOnlyConflicts = []string{
+	"dump", "no-stats", "rollup-dir", "sqlite", "only", "url-pattern", "url-pattern-invert", "since",
+	"min-response-time", "max-response-time",
+	"status-min", "method", "decode-urls", "error-codes", "head", "tail", "explain",
+}
+
+// Флаги рейтингов, которые не имеют смысла с --summary-only: рейтинги не считаются
+var summaryOnlyConflicts = []string{"top-by", "top-endpoints", "group-by-param", "group-by-prefix", "resolve-dns", "verbose"}
+
+// Флаги, которые действуют только вместе с другим флагом: флаг → нужный флаг
+var flagRequires = [][2]string{
+	{"status-min", "dump"},
+	{"method", "dump"},
+	{"tee-buffer", "dump"},
+	{"tee-spill", "dump"},
+	{"tee-spill-dir", "tee-spill"},
+	{"relative-to", "since"},
+	{"socket-mode", "socket"},
+	{"error-log-window", "error-log"},
+	{"rollup-interval", "rollup-dir"},
+	{"sqlite-batch", "sqlite"},
+	{"record-start", "multiline"},
+	{"spike-interval", "spike-factor"},
+}
+
+// Префикс переменных окружения с настройками: LOGPROC_WORKERS для --workers,
+// LOGPROC_TEE_BUFFER для --tee-buffer
+const envPrefix = "LOGPROC_"
+
+// Имя переменной окружения для флага name
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Задаем флаги, которых нет в командной строке, из переменных окружения envName(flag).
+// Флаги командной строки имеют приоритет; переменные для флагов, которых нет у
+// подкоманды, не учитываются. Неверное значение — такая же ошибка, как в флаге.
+func (cfg *cliConfig) applyEnv() {
+	set := make(map[string]bool)
+	cfg.flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	cfg.flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || set[f.Name] {
+			return
+		}
+		if err := cfg.flags.Set(f.Name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "неверное значение %s=%q: %v\n", envName(f.Name), value, err)
+			os.Exit(exitCodeUsage)
+		}
+	})
+}
+
+// Проверяем сочетания явно заданных флагов (в том числе через переменные окружения):
+// противоречивые сочетания отклоняются
+// с кодом завершения exitCodeUsage, чтобы запуск не делал молча что-то неожиданное.
+func (cfg *cliConfig) checkFlagCombinations() {
+	problems := cfg.flagConflicts()
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "несовместимые флаги: %s\n", problem)
+		}
+		os.Exit(exitCodeUsage)
+	}
+}
+
+// Противоречивые сочетания явно заданных флагов, по одному описанию на проблему.
+// Требования проверяются, только если нужный флаг есть у подкоманды: у filter,
+// например, нет --dump, выгрузка там включена всегда.
+func (cfg *cliConfig) flagConflicts() []string {
+	set := make(map[string]bool)
+	cfg.flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var problems []string
+	if set["no-stats"] {
+		// Без статистики запуск полезен, только если что-то выгружает
+		if !set["dump"] && !set["rollup-dir"] && !set["sqlite"] {
+			problems = append(problems, "--no-stats действует только вместе с --dump, --rollup-dir или --sqlite")
+		}
+		for _, name := range statsOnlyFlags {
+			if set[name] {
+				problems = append(problems, fmt.Sprintf("--%s не действует с --no-stats: статистика не считается", name))
+			}
+		}
+	}
+	if set["count-only"] {
+		for _, name := range slices.Concat(countOnlyConflicts, statsOnlyFlags) {
+			if set[name] {
+				problems = append(problems, fmt.Sprintf("--%s не действует с --count-only: записи только считаются", name))
+			}
+		}
+	}
+	for _, rule := range flagRequires {
+		name, required := rule[0], rule[1]
+		if set[name] && !set[required] && cfg.flags.Lookup(required) != nil {
+			problems = append(problems, fmt.Sprintf("--%s действует только вместе с --%s", name, required))
+		}
+	}
+	if set["anonymize-ip"] && set["ip-hash"] {
+		problems = append(problems, "--anonymize-ip и --ip-hash нельзя задать вместе: оба заменяют IP")
+	}
+	for _, name := range []string{"anonymize-ip", "ip-hash"} {
+		if set[name] && set["error-log"] {
+			problems = append(problems, fmt.Sprintf("--%s не действует с --error-log: сопоставление с журналом ошибок идет по точному IP", name))
+		}
+		if set[name] && set["resolve-dns"] {
+			problems = append(problems, fmt.Sprintf("--resolve-dns не действует с --%s: обезличенные адреса не разрешаются", name))
+		}
+	}
+	if set["anonymize-salt"] && cfg.anonymizeIP != "hash" && cfg.ipHash != "keyed" {
+		problems = append(problems, "--anonymize-salt действует только с --anonymize-ip=hash или --ip-hash=keyed")
+	}
+	if set["summary-only"] {
+		for _, name := range summaryOnlyConflicts {
+			if set[name] {
+				problems = append(problems, fmt.Sprintf("--%s не действует с --summary-only: рейтинги не считаются", name))
+			}
+		}
+	}
+	if cfg.urlPatternNegated && set["url-pattern-invert"] {
+		problems = append(problems, "--url-pattern с префиксом ! и --url-pattern-invert нельзя задать вместе: оба задают исключаемые URL")
+	}
+	if set["progress"] && set["tui"] {
+		problems = append(problems, "--progress не действует с --tui: экран TUI сам показывает ход обработки")
+	}
+	if set["field-widths"] && cfg.inputFormat != "fixed" {
+		problems = append(problems, "--field-widths действует только с --input-format=fixed")
+	}
+	return problems
+}
+
+// Разбираем аргументы подкоманды и готовим настройки к запуску: собираем входные
+// файлы, проверяем значения, загружаем схему и журнал ошибок. Возвращает false,
+// если входные файлы не заданы (тогда выводится справка по подкоманде).
+func (cfg *cliConfig) parse(args []string) bool {
+	cfg.flags.Parse(args)
+	cfg.applyEnv()
+	cfg.checkFlagCombinations()
+	opts := &cfg.opts
+
+	// Входные файлы: аргументы командной строки и список из манифеста
+	cfg.inputFiles = cfg.flags.Args()
+	if cfg.filesFrom != "" {
+		names, err := readManifest(cfg.filesFrom)
+		if err != nil {
+			log.Fatalf("ошибка чтения списка файлов: %v", err)
+		}
+		cfg.inputFiles = append(cfg.inputFiles, names...)
+	}
+	if cfg.kafkaTopic != "" {
+		cfg.inputFiles = append(cfg.inputFiles, kafkaScheme+cfg.kafkaTopic)
+	}
+	if cfg.socketPath != "" {
+		cfg.inputFiles = append(cfg.inputFiles, socketScheme+cfg.socketPath)
+	}
+	files, err := expandGlobs(cfg.inputFiles)
+	if err != nil {
+		log.Fatalf("ошибка в списке входных файлов: %v", err)
+	}
+	cfg.inputFiles = files
+	if cfg.lastNFiles > 0 {
+		if cfg.inputFiles, err = newestFiles(cfg.inputFiles, cfg.lastNFiles); err != nil {
+			log.Fatalf("ошибка выбора последних файлов: %v", err)
+		}
+	}
+
+	// Проверяем аргументы командной строки: ожидаем имя файла с логами
+	if len(cfg.inputFiles) < 1 {
+		fmt.Printf("Запуск: go run . %s [флаги] <logfile.csv | URL>...\n", cfg.flags.Name())
+		cfg.flags.PrintDefaults()
+		return false
+	}
+
+	if cfg.follow {
+		name := cfg.inputFiles[0]
+		if len(cfg.inputFiles) > 1 || isHTTPURL(name) || isKafkaInput(name) || isSocketInput(name) {
+			log.Fatalf("--follow действует только для одного локального файла")
+		}
+		cfg.inputOpts.FollowInterval = defaultFollowInterval
+	}
+	if opts.TeeBufferSize < 0 {
+		log.Fatalf("размер буфера tee не может быть отрицательным: %d", opts.TeeBufferSize)
+	}
+	if opts.Head < 0 || opts.Tail < 0 {
+		log.Fatalf("--head и --tail не могут быть отрицательными")
+	}
+	if opts.SlowThreshold < 0 || opts.FastThreshold < 0 {
+		log.Fatalf("пороги --slow-threshold и --fast-threshold не могут быть отрицательными")
+	}
+	if opts.GapInterval != 0 && opts.GapInterval < time.Second {
+		log.Fatalf("--gap-interval должен быть не меньше 1s (время в логах с точностью до секунды): %v", opts.GapInterval)
+	}
+	if opts.MinResponseTime > opts.MaxResponseTime {
+		log.Fatalf("--min-response-time больше --max-response-time: пропускать нечего")
+	}
+	if opts.SpikeFactor < 0 {
+		log.Fatalf("--spike-factor не может быть отрицательным: %g", opts.SpikeFactor)
+	}
+	if opts.SpikeInterval < time.Second {
+		log.Fatalf("--spike-interval должен быть не меньше 1s (время в логах с точностью до секунды): %v", opts.SpikeInterval)
+	}
+	if cfg.stallTimeout < 0 {
+		log.Fatalf("--stall-timeout не может быть отрицательным: %v", cfg.stallTimeout)
+	}
+	if opts.RollupDir != "" && opts.RollupInterval <= 0 {
+		log.Fatalf("--rollup-interval должен быть больше 0: %v", opts.RollupInterval)
+	}
+	if opts.SQLiteBatch < 1 {
+		log.Fatalf("размер пачки --sqlite-batch должен быть не меньше 1: %d", opts.SQLiteBatch)
+	}
+	if opts.Workers < 1 {
+		log.Fatalf("количество воркеров должно быть не меньше 1: %d", opts.Workers)
+	}
+
+	opts.NormalizeMethod = !cfg.noNormalizeMethod
+	if cfg.multiline && opts.RecordStart == nil {
+		opts.RecordStart = defaultRecordStart
+	}
+	if cfg.anonymizeIP != "" {
+		opts.AnonymizeIP = newIPAnonymizer(cfg.anonymizeIP, cfg.anonymizeSalt)
+	}
+	if cfg.ipHash != "" {
+		opts.AnonymizeIP = newIPHasher(cfg.ipHash, cfg.anonymizeSalt)
+	}
+	// --verbose добавляет гистограмму по часам к показателям по умолчанию
+	// (явный список --stats не меняется)
+	if opts.Verbose && opts.Stats == statDefault {
+		opts.Stats |= statHourOfDay
+	}
+	opts.Heatmap = cfg.heatmapOut != ""
+	opts.CountWorkers = cfg.timing
+	if cfg.summaryOnly {
+		opts.Stats &^= statRankings
+	}
+
+	if !validReportFormat(cfg.format) {
+		log.Fatalf("неизвестный формат отчета: %s (допустимо: %s)", cfg.format, strings.Join(reportFormats, ", "))
+	}
+
+	// Явно заданный --thousands-sep имеет приоритет над --locale
+	if cfg.locale != "" {
+		sep, ok := localeThousandsSep[cfg.locale]
+		if !ok {
+			log.Fatalf("неизвестная локаль: %s", cfg.locale)
+		}
+		explicitSep := false
+		cfg.flags.Visit(func(f *flag.Flag) {
+			explicitSep = explicitSep || f.Name == "thousands-sep"
+		})
+		if !explicitSep {
+			thousandsSep = sep
+		}
+	}
+
+	// Загружаем и проверяем схему колонок до начала обработки
+	if cfg.schemaFile != "" {
+		schema, err := loadSchema(cfg.schemaFile, opts.TimeUnit)
+		if err != nil {
+			log.Fatalf("ошибка схемы: %v", err)
+		}
+		opts.Schema = &schema
+	} else if slices.ContainsFunc(cfg.inputFiles, isKafkaInput) {
+		// В сообщениях Kafka нет строки заголовка: по умолчанию используем исходную схему
+		schema := defaultSchema()
+		schema.hasHeader = false
+		schema.timeUnit = opts.TimeUnit
+		opts.Schema = &schema
+	}
+
+	// Загружаем журнал ошибок целиком: он нужен для поиска по времени
+	if cfg.errorLogFile != "" {
+		errLog, err := loadErrorLog(cfg.errorLogFile)
+		if err != nil {
+			log.Fatalf("ошибка чтения журнала ошибок: %v", err)
+		}
+		opts.ErrorLog = errLog
+	}
+
+	// Загружаем и проверяем цели SLO до начала обработки
+	if cfg.sloConfigFile != "" {
+		targets, err := loadSLOConfig(cfg.sloConfigFile)
+		if err != nil {
+			log.Fatalf("ошибка конфигурации SLO: %v", err)
+		}
+		opts.SLOs = targets
+	}
+
+	// Строки фиксированной ширины: ширины колонок задаются отдельно от схемы
+	switch cfg.inputFormat {
+	case "csv":
+	case "fixed":
+		if cfg.fieldWidths == "" {
+			log.Fatalf("для --input-format=fixed нужен --field-widths")
+		}
+		widths, err := parseFieldWidths(cfg.fieldWidths)
+		if err != nil {
+			log.Fatalf("ошибка --field-widths: %v", err)
+		}
+		if cfg.schemaFile != "" {
+			// Индексы полей из --schema — номера колонок фиксированной ширины
+			if len(widths) != opts.Schema.columns {
+				log.Fatalf("в --field-widths %d колонок, а в схеме %d", len(widths), opts.Schema.columns)
+			}
+			opts.Schema.widths = widths
+		} else {
+			schema, err := fixedWidthSchema(widths)
+			if err != nil {
+				log.Fatalf("ошибка --field-widths: %v", err)
+			}
+			schema.timeUnit = opts.TimeUnit
+			opts.Schema = &schema
+		}
+	default:
+		log.Fatalf("неизвестный формат входных данных: %s (допустимо: csv, fixed)", cfg.inputFormat)
+	}
+
+	// Выгрузка отфильтрованных записей в stdout
+	if cfg.dump {
+		opts.Dump = os.Stdout
+	}
+	return true
+}
+
+// Завершаем вывод --progress итоговой строкой, чтобы он не смешивался с отчетом
+func (cfg *cliConfig) finishProgress() {
+	if cfg.stopProgress != nil {
+		cfg.stopProgress()
+	}
+}
+
+// Выводим для --timing время с начала обработки, количество строк и скорость.
+// workers — размер пула воркеров (0 — чтение без пула, как у --count-only и validate).
+// Скорость при разном --workers на одних данных показывает выигрыш от параллельности.
+func (cfg *cliConfig) reportTiming(lines, workers int) {
+	if !cfg.timing {
+		return
+	}
+	elapsed := time.Since(cfg.startedAt)
+	rate := 0
+	if elapsed > 0 {
+		rate = int(float64(lines) / elapsed.Seconds())
+	}
+	pool := "без пула воркеров"
+	if workers > 0 {
+		pool = fmt.Sprintf("воркеров: %d", workers)
+	}
+	fmt.Fprintf(os.Stderr, "Обработано %s строк за %v (%s строк/с), %s\n",
+		formatCount(lines), elapsed.Round(time.Millisecond), formatCount(rate), pool)
+}
+
+// Запуск обработки: контекст с отменой по Ctrl+C и --timeout, профилирование,
+// файл отклоненных строк и открытые входные данные. stop освобождает все это;
+// повторные вызовы безопасны, поэтому ее можно и отложить, и вызвать перед os.Exit.
+func (cfg *cliConfig) start() (ctx context.Context, input io.Reader, stop func()) {
+	cfg.startedAt = time.Now()
+	var cleanups []func()
+	stop = func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+		cleanups = nil
+	}
+
+	// Создаем контекст, который отменяется по Ctrl+C: чтение останавливается,
+	// а статистика по уже прочитанным записям выводится
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	cleanups = append(cleanups, cancel)
+
+	// Если задан таймаут — оборачиваем контекст, по истечении времени
+	// все стадии pipeline завершатся через обычный путь отмены
+	if cfg.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		cleanups = append(cleanups, cancel)
+	}
+
+	// Профилирование охватывает весь pipeline: от открытия входных данных до отчета
+	stopProfiling, err := startProfiling(cfg.cpuProfile, cfg.memProfile)
+	if err != nil {
+		log.Fatalf("ошибка запуска профилирования: %v", err)
+	}
+	cleanups = append(cleanups, stopProfiling)
+
+	// Открываем файл для отклоненных строк, если он задан
+	if cfg.rejectsFile != "" {
+		f, err := os.Create(cfg.rejectsFile)
+		if err != nil {
+			log.Fatalf("ошибка создания файла отклоненных строк: %v", err)
+		}
+		cleanups = append(cleanups, func() { f.Close() })
+		cfg.opts.Rejects = f
+	}
+
+	// Счетчик прогресса подключается к файлам при открытии
+	if cfg.progress {
+		cfg.inputOpts.Progress = &progressCounter{total: inputSize(cfg.inputFiles)}
+	}
+
+	// Открываем файлы (или URL) с логами
+	in, err := openInputs(ctx, cfg.inputFiles, cfg.inputOpts)
+	if err != nil {
+		log.Fatalf("ошибка чтения логов: %v", err)
+	}
+	cleanups = append(cleanups, func() { in.Close() })
+
+	if cfg.progress {
+		cfg.stopProgress = cfg.inputOpts.Progress.start(os.Stderr)
+		cleanups = append(cleanups, cfg.stopProgress)
+	}
+
+	// Сторож зависания следит за pipeline до конца обработки
+	if cfg.stallTimeout > 0 {
+		cfg.opts.Watchdog = &stallWatchdog{timeout: cfg.stallTimeout}
+		cleanups = append(cleanups, cfg.opts.Watchdog.start(os.Stderr))
+	}
+
+	return ctx, in, stop
+}
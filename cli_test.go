@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Настройки подкоманды analyze со всеми ее флагами
+func newTestAnalyzeConfig() *cliConfig {
+	cfg := newCLIConfig("analyze")
+	cfg.addInputFlags()
+	cfg.addRunFlags()
+	cfg.addFilterFlags()
+	cfg.addStatsFlags()
+	return cfg
+}
+
+func TestURLPattern(t *testing.T) {
+	urls := []string{"/api/users", "/api/users/1?x=1", "/static/app.js", "/health"}
+	tests := []struct {
+		name string
+		args []string
+		want []bool
+	}{
+		{"без шаблона", nil, []bool{true, true, true, true}},
+		{"шаблон", []string{"--url-pattern=^/api/"}, []bool{true, true, false, false}},
+		{"с префиксом !", []string{"--url-pattern=!^/static/"}, []bool{true, true, false, true}},
+		{"--url-pattern-invert", []string{"--url-pattern-invert=^/static/"}, []bool{true, true, false, true}},
+		{"шаблон и исключение", []string{"--url-pattern=^/api/", "--url-pattern-invert=/1"}, []bool{true, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAnalyzeConfig()
+			if err := cfg.flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			match := urlFilter(cfg.opts)
+			for i, url := range urls {
+				if got := match(LogEntry{URL: url}); got != tt.want[i] {
+					t.Errorf("%s: %v, ожидалось %v", url, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFlagConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"без флагов", nil, nil},
+		{"совместимые флаги", []string{"--dump", "--status-min=400", "--top-by=url-errors"}, nil},
+		{"--no-stats без выгрузки", []string{"--no-stats"}, []string{
+			"--no-stats действует только вместе с --dump, --rollup-dir или --sqlite",
+		}},
+		{"--no-stats и статистика", []string{"--no-stats", "--dump", "--verbose"}, []string{
+			"--verbose не действует с --no-stats: статистика не считается",
+		}},
+		{"--count-only и отбор", []string{"--count-only", "--head=10"}, []string{
+			"--head не действует с --count-only: записи только считаются",
+		}},
+		{"требуемый флаг", []string{"--status-min=400"}, []string{
+			"--status-min действует только вместе с --dump",
+		}},
+		{"--anonymize-ip и --ip-hash", []string{"--anonymize-ip=hash", "--ip-hash=fast"}, []string{
+			"--anonymize-ip и --ip-hash нельзя задать вместе: оба заменяют IP",
+		}},
+		{"--url-pattern с ! и --url-pattern-invert", []string{"--url-pattern=!^/static/", "--url-pattern-invert=^/health"}, []string{
+			"--url-pattern с префиксом ! и --url-pattern-invert нельзя задать вместе: оба задают исключаемые URL",
+		}},
+		{"--field-widths без fixed", []string{"--field-widths=19,15,7,30,3,6"}, []string{
+			"--field-widths действует только с --input-format=fixed",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAnalyzeConfig()
+			if err := cfg.flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.flagConflicts(); !slices.Equal(got, tt.want) {
+				t.Errorf("проблемы %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// У filter нет --dump: требование --status-min к нему не проверяется
+func TestFlagConflictsMissingFlag(t *testing.T) {
+	cfg := newCLIConfig("filter")
+	cfg.addInputFlags()
+	cfg.addRunFlags()
+	cfg.addFilterFlags()
+	if err := cfg.flags.Parse([]string{"--status-min=400"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.flagConflicts(); got != nil {
+		t.Errorf("проблемы %q, ожидалось без проблем", got)
+	}
+}
+
+// Несовместимые флаги завершают запуск с кодом exitCodeUsage. os.Exit проверяем
+// в отдельном процессе: тест перезапускает сам себя с LOG_PROCESSOR_TEST_EXIT=1
+func TestFlagConflictsExitCode(t *testing.T) {
+	if os.Getenv("LOG_PROCESSOR_TEST_EXIT") == "1" {
+		newTestAnalyzeConfig().parse([]string{"--anonymize-ip=hash", "--ip-hash=fast", "logs.csv"})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFlagConflictsExitCode$")
+	cmd.Env = append(os.Environ(), "LOG_PROCESSOR_TEST_EXIT=1")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitCodeUsage {
+		t.Fatalf("завершение %v, ожидался код %d", err, exitCodeUsage)
+	}
+	if !strings.Contains(stderr.String(), "несовместимые флаги: --anonymize-ip и --ip-hash") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Сколько позиций рейтингов сравнивается в --compare
+const compareTopN = 5
+
+// Загружаем статистику прошлого запуска для сравнения (--compare), сохраненную
+// через --save-stats или --state
+func loadBaseline(path string) (Statistics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Statistics{}, err
+	}
+	var baseline Statistics
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return Statistics{}, fmt.Errorf("ошибка разбора %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// Сравнение текущего запуска с прошлым: значения показателей рядом, абсолютное
+// изменение и изменение в процентах (для долей — в процентных пунктах),
+// а также ключи, которые вошли в топ или выбыли из него.
+func printComparison(w io.Writer, name string, base, cur Statistics) {
+	fmt.Fprintf(w, "Сравнение с %s:\n", name)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tбыло\tстало\tизменение")
+
+	count := func(title string, was, now int) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", title, formatCount(was), formatCount(now), countDelta(was, now))
+	}
+	millis := func(title string, was, now float64) {
+		fmt.Fprintf(tw, "%s\t%.2f ms\t%.2f ms\t%s\n", title, was, now, floatDelta(was, now))
+	}
+
+	count("Всего запросов", base.TotalRequests, cur.TotalRequests)
+	count("Ошибок", base.ErrorCount, cur.ErrorCount)
+	wasRate, nowRate := percent(base.ErrorCount, base.TotalRequests), percent(cur.ErrorCount, cur.TotalRequests)
+	fmt.Fprintf(tw, "Доля ошибок\t%.1f%%\t%.1f%%\t%+.1f п.п.\n", wasRate, nowRate, nowRate-wasRate)
+	millis("Среднее время ответа", base.AverageRespTime, cur.AverageRespTime)
+	millis("Стандартное отклонение", base.StdDevRespTime, cur.StdDevRespTime)
+	count("Пиковая нагрузка, запросов/с", base.PeakRate, cur.PeakRate)
+	methods := unionKeys(base.RequestsByMethod, cur.RequestsByMethod)
+	slices.Sort(methods)
+	for _, method := range methods {
+		count("Метод "+method, base.RequestsByMethod[method], cur.RequestsByMethod[method])
+	}
+	tw.Flush()
+
+	printTopChanges(w, "IP", base.RequestsByIP, cur.RequestsByIP)
+	printTopChanges(w, "URL", base.RequestsByURL, cur.RequestsByURL)
+}
+
+// Изменение количества: "+1,200 (+4.1%)"; процент не выводится, если раньше было 0
+func countDelta(was, now int) string {
+	delta := "+" + formatCount(now-was)
+	if now < was {
+		delta = "-" + formatCount(was-now)
+	}
+	if was == 0 {
+		return delta
+	}
+	return fmt.Sprintf("%s (%+.1f%%)", delta, float64(now-was)/float64(was)*100)
+}
+
+// Изменение величины с дробной частью: "+1.50 (+3.0%)"
+func floatDelta(was, now float64) string {
+	if was == 0 {
+		return fmt.Sprintf("%+.2f", now-was)
+	}
+	return fmt.Sprintf("%+.2f (%+.1f%%)", now-was, (now-was)/was*100)
+}
+
+// Ключи, которые встречаются хотя бы в одной из карт
+func unionKeys(a, b map[string]int) []string {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	result := make([]string, 0, len(keys))
+	for key := range keys {
+		result = append(result, key)
+	}
+	return result
+}
+
+// Вывод ключей, которые вошли в топ или выбыли из него (по количеству запросов)
+func printTopChanges(w io.Writer, title string, base, cur map[string]int) {
+	if base == nil || cur == nil {
+		return
+	}
+	was, now := topKeys(base), topKeys(cur)
+	var added, removed []string
+	for _, key := range now {
+		if !slices.Contains(was, key) {
+			added = append(added, fmt.Sprintf("%s (%s)", key, formatCount(cur[key])))
+		}
+	}
+	for _, key := range was {
+		if !slices.Contains(now, key) {
+			removed = append(removed, fmt.Sprintf("%s (было %s)", key, formatCount(base[key])))
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Fprintf(w, "Топ %d %s не изменился\n", compareTopN, title)
+		return
+	}
+	if len(added) > 0 {
+		fmt.Fprintf(w, "Новые в топ %d %s: %s\n", compareTopN, title, strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		fmt.Fprintf(w, "Выбыли из топ %d %s: %s\n", compareTopN, title, strings.Join(removed, ", "))
+	}
+}
+
+// Ключи топ-N по количеству
+func topKeys(counts map[string]int) []string {
+	var keys []string
+	for _, kc := range topN(counts, compareTopN) {
+		keys = append(keys, kc.key)
+	}
+	return keys
+}
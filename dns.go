@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ограничения обратного разрешения для --resolve-dns: время на один запрос
+// и сколько запросов выполняется одновременно
+const (
+	dnsLookupTimeout     = 2 * time.Second
+	dnsLookupConcurrency = 8
+)
+
+// IP, которые выводит отчет в рейтингах (топ IP или --top-by по IP): только их
+// имеет смысл разрешать, поэтому количество запросов к DNS не больше n
+func reportedIPs(stats Statistics, opts Options, n int) []string {
+	var counts map[string]int
+	switch {
+	case opts.TopBy == "ip-requests" || opts.TopBy == "ip-bytes":
+		_, _, counts = topByRanking(stats, opts.TopBy)
+	case opts.TopBy == "" && opts.Stats.has(statTopIPs):
+		counts = stats.RequestsByIP
+	}
+	var ips []string
+	for _, kc := range topN(counts, n) {
+		ips = append(ips, kc.key)
+	}
+	return ips
+}
+
+// Имена хостов для ips по обратным записям DNS (--resolve-dns). Каждый адрес
+// разрешается один раз, не больше dnsLookupConcurrency запросов одновременно, каждый
+// с таймаутом dnsLookupTimeout. Адреса, которые не разрешились (или не являются IP,
+// например после --anonymize-ip=hash), в результат не попадают.
+func resolveHostnames(ctx context.Context, ips []string) map[string]string {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		hostnames = make(map[string]string)
+		seen      = make(map[string]bool)
+		sem       = make(chan struct{}, dnsLookupConcurrency)
+	)
+	for _, ip := range ips {
+		if seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		if _, err := netip.ParseAddr(ip); err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+			defer cancel()
+			names, err := net.DefaultResolver.LookupAddr(lookupCtx, ip)
+			if err != nil || len(names) == 0 {
+				return
+			}
+			mu.Lock()
+			hostnames[ip] = strings.TrimSuffix(names[0], ".")
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return hostnames
+}
+
+// IP с именем хоста, если оно известно: "203.0.113.5 (crawler.example.com)"
+func withHostname(ip string, hostnames map[string]string) string {
+	if name, ok := hostnames[ip]; ok {
+		return ip + " (" + name + ")"
+	}
+	return ip
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Сообщение из журнала ошибок (--error-log)
+type errorLogMessage struct {
+	Timestamp time.Time
+	Message   string
+}
+
+// Журнал ошибок: сообщения каждого IP, отсортированные по времени
+type errorLog map[string][]errorLogMessage
+
+// Ответ 5xx и ближайшее по времени сообщение того же IP из журнала ошибок
+type errorCorrelation struct {
+	Entry   LogEntry
+	Message string // пусто, если в окне подходящего сообщения нет
+}
+
+// Загружаем журнал ошибок. Формат — CSV с колонками timestamp,ip,message
+// (сообщение может содержать запятые). Строки, время которых не разбирается,
+// например заголовок, пропускаются.
+func loadErrorLog(path string) (errorLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages := make(errorLog)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(cleanLine(scanner.Text()), ",", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		ts, err := time.Parse(timestampLayout, strings.TrimSpace(parts[0]))
+		if err != nil {
+			continue
+		}
+		ip := strings.TrimSpace(parts[1])
+		messages[ip] = append(messages[ip], errorLogMessage{ts, strings.TrimSpace(parts[2])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, list := range messages {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Timestamp.Before(list[j].Timestamp)
+		})
+	}
+	return messages, nil
+}
+
+// Ближайшее по времени сообщение для ip не дальше window от ts (при равном
+// расстоянии берется более раннее). ok == false, если такого сообщения нет.
+func (l errorLog) nearest(ip string, ts time.Time, window time.Duration) (message string, ok bool) {
+	list := l[ip]
+	// Первое сообщение не раньше ts; ближайшее — оно или предыдущее
+	i := sort.Search(len(list), func(i int) bool {
+		return !list[i].Timestamp.Before(ts)
+	})
+	best := window + 1
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(list) {
+			continue
+		}
+		distance := list[j].Timestamp.Sub(ts).Abs()
+		if distance < best {
+			best = distance
+			message = list[j].Message
+		}
+	}
+	return message, best <= window
+}
+
+// Сопоставляем ответы 5xx с журналом ошибок: по совпадению IP и ближайшему
+// времени в пределах window
+func correlateErrors(entries []LogEntry, l errorLog, window time.Duration) []errorCorrelation {
+	correlations := make([]errorCorrelation, 0, len(entries))
+	for _, entry := range entries {
+		correlation := errorCorrelation{Entry: entry}
+		if ts, err := time.Parse(timestampLayout, entry.Timestamp); err == nil {
+			correlation.Message, _ = l.nearest(entry.IP, ts, window)
+		}
+		correlations = append(correlations, correlation)
+	}
+	return correlations
+}
+
+// Вывод ответов 5xx с сообщениями из журнала ошибок (в порядке времени)
+func printErrorCorrelations(w io.Writer, correlations []errorCorrelation) {
+	if len(correlations) == 0 {
+		return
+	}
+	sort.SliceStable(correlations, func(i, j int) bool {
+		return correlations[i].Entry.Timestamp < correlations[j].Entry.Timestamp
+	})
+
+	matched := 0
+	fmt.Fprintln(w, "Ответы 5xx и сообщения из журнала ошибок:")
+	for _, c := range correlations {
+		e := c.Entry
+		message := "(нет сообщения)"
+		if c.Message != "" {
+			message = c.Message
+			matched++
+		}
+		fmt.Fprintf(w, "  %s | %s | %s %s | %d | %s\n", e.Timestamp, e.IP, e.Method, e.URL, e.StatusCode, message)
+	}
+	fmt.Fprintf(w, "Найдены сообщения для %s из %s ответов 5xx\n", formatCount(matched), formatCount(len(correlations)))
+}
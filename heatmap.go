@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Подписи строк тепловой карты: дни недели с понедельника
+var heatmapDays = [7]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// Индекс дня недели в RequestsByWeekdayHour: 0 — понедельник, 6 — воскресенье
+func weekdayIndex(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+// Тепловая карта в CSV (--heatmap-out): заголовок "day,00,01,...,23", затем строка
+// на каждый день недели с количеством запросов по часам
+func formatHeatmapCSV(counts [7][24]int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("day")
+	for hour := range 24 {
+		fmt.Fprintf(&buf, ",%02d", hour)
+	}
+	buf.WriteString("\n")
+	for day, hours := range counts {
+		buf.WriteString(heatmapDays[day])
+		for _, count := range hours {
+			fmt.Fprintf(&buf, ",%d", count)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
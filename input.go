@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+	"golang.org/x/time/rate"
+)
+
+// Настройки получения входных данных
+type InputOptions struct {
+	HTTPRetries int           // количество повторов при загрузке по HTTP
+	HTTPBackoff time.Duration // пауза перед первым повтором, далее удваивается
+
+	KafkaBrokers        []string      // адреса брокеров Kafka
+	KafkaGroup          string        // группа потребителей Kafka
+	KafkaCommitInterval time.Duration // как часто коммитить смещения Kafka
+
+	SocketListen bool // для Unix сокета: создать сокет и ждать подключения (иначе — подключиться)
+
+	Encoding encoding.Encoding // кодировка входных данных (nil — UTF-8, без перекодирования)
+
+	Progress *progressCounter // счетчик прочитанных байтов файлов для --progress (nil — не считать)
+
+	// С --follow: как часто проверять, не дописан ли файл, после того как он прочитан
+	// до конца (0 — читать до конца и завершаться)
+	FollowInterval time.Duration
+}
+
+// Интервал опроса дописываемого файла для --follow
+const defaultFollowInterval = time.Second
+
+// Поддерживаемые кодировки для --encoding (nil — UTF-8 без перекодирования)
+var inputEncodings = map[string]encoding.Encoding{
+	"utf-8":        nil,
+	"windows-1251": charmap.Windows1251,
+	"cp1251":       charmap.Windows1251,
+	"koi8-r":       charmap.KOI8R,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+}
+
+// Разбираем значение флага --encoding (регистр не важен)
+func parseEncoding(name string) (encoding.Encoding, error) {
+	enc, ok := inputEncodings[strings.ToLower(name)]
+	if !ok {
+		names := slices.Sorted(maps.Keys(inputEncodings))
+		return nil, fmt.Errorf("неизвестная кодировка %q (допустимо: %s)", name, strings.Join(names, ", "))
+	}
+	return enc, nil
+}
+
+// Настройки получения входных данных по умолчанию
+func defaultInputOptions() InputOptions {
+	return InputOptions{
+		HTTPRetries: 3,
+		HTTPBackoff: time.Second,
+
+		KafkaGroup:          "log-processor",
+		KafkaCommitInterval: 5 * time.Second,
+	}
+}
+
+// Объединяет распакованный поток и закрытие исходного источника
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Открываем источник логов: локальный файл, URL (http/https), топик Kafka (kafka://topic)
+// или Unix сокет (unix://path). Сжатые данные автоматически распаковываются.
+// Закрывать результат должен вызывающий.
+func openInput(ctx context.Context, name string, opts InputOptions) (io.ReadCloser, error) {
+	if isKafkaInput(name) {
+		return openKafka(ctx, strings.TrimPrefix(name, kafkaScheme), opts)
+	}
+	if isSocketInput(name) {
+		return openSocket(ctx, strings.TrimPrefix(name, socketScheme), opts)
+	}
+
+	var src io.ReadCloser
+	if isHTTPURL(name) {
+		body, err := fetchHTTP(ctx, name, opts.HTTPRetries, opts.HTTPBackoff)
+		if err != nil {
+			return nil, err
+		}
+		src = body
+	} else {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		src = file
+		if opts.FollowInterval > 0 {
+			src = readCloser{&followReader{ctx: ctx, r: file, interval: opts.FollowInterval}, file}
+		}
+	}
+
+	// Для --progress считаем байты до распаковки: их сумма сравнивается с размером файлов
+	var raw io.Reader = src
+	if opts.Progress != nil {
+		raw = countingReader{src, opts.Progress}
+	}
+
+	// Если данные сжаты (gzip, bzip2, xz) — читаем через распаковщик
+	reader, err := decompress(raw)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return readCloser{reader, src}, nil
+}
+
+// Открываем несколько источников логов как один поток.
+// Один источник открывается сразу; при нескольких файлы открываются по очереди
+// по мере чтения, чтобы не держать открытыми тысячи файлов одновременно.
+// Все файлы должны иметь одинаковую схему: повторные заголовки пропускает readLogs.
+// Если задана кодировка, данные перекодируются в UTF-8 после распаковки.
+func openInputs(ctx context.Context, names []string, opts InputOptions) (io.ReadCloser, error) {
+	var input io.ReadCloser = &multiInput{ctx: ctx, names: names, opts: opts}
+	if len(names) == 1 {
+		var err error
+		if input, err = openInput(ctx, names[0], opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Encoding != nil {
+		input = readCloser{transform.NewReader(input, opts.Encoding.NewDecoder()), input}
+	}
+	return input, nil
+}
+
+// Последовательное чтение нескольких источников. Если источник закончился не
+// переводом строки, перед следующим добавляется "\n", чтобы последняя строка файла
+// не склеилась с заголовком следующего.
+type multiInput struct {
+	ctx     context.Context
+	names   []string
+	opts    InputOptions
+	current io.ReadCloser
+
+	midLine        bool // последний прочитанный фрагмент не закончился переводом строки
+	pendingNewline bool // источник закончился посреди строки: следующий Read выдает "\n"
+}
+
+func (m *multiInput) Read(p []byte) (int, error) {
+	if m.pendingNewline && len(p) > 0 {
+		m.pendingNewline = false
+		m.midLine = false
+		p[0] = '\n'
+		return 1, nil
+	}
+	if m.current == nil {
+		if len(m.names) == 0 {
+			return 0, io.EOF
+		}
+		rc, err := openInput(m.ctx, m.names[0], m.opts)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", m.names[0], err)
+		}
+		m.names = m.names[1:]
+		m.current = rc
+	}
+
+	// Распаковщики (gzip, bzip2) могут вернуть последние данные вместе с io.EOF
+	n, err := m.current.Read(p)
+	if n > 0 {
+		m.midLine = p[n-1] != '\n'
+	}
+	if err == io.EOF {
+		m.current.Close()
+		m.current = nil
+		m.pendingNewline = m.midLine
+		return n, nil
+	}
+	return n, err
+}
+
+func (m *multiInput) Close() error {
+	if m.current != nil {
+		return m.current.Close()
+	}
+	return nil
+}
+
+// Читаем список входных файлов из манифеста: по одному пути в строке,
+// пустые строки и строки, начинающиеся с "#", пропускаются.
+// Относительные пути считаются относительно каталога манифеста.
+func readManifest(manifest string) ([]string, error) {
+	file, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(manifest)
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if !isHTTPURL(name) && !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Раскрываем шаблоны в именах входных файлов ("logs/access-*.csv"), если их не
+// раскрыла оболочка: имя в кавычках или запуск под Windows. Найденные файлы
+// подставляются на место шаблона в порядке сортировки; URL и топики Kafka не трогаем.
+func expandGlobs(names []string) ([]string, error) {
+	var expanded []string
+	for _, name := range names {
+		if isHTTPURL(name) || isKafkaInput(name) || isSocketInput(name) || !strings.ContainsAny(name, "*?[") {
+			expanded = append(expanded, name)
+			continue
+		}
+		matches, err := filepath.Glob(name)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный шаблон %q: %w", name, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("нет файлов, подходящих под шаблон %q", name)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// Оставляем из локальных файлов n последних по времени изменения (--last-n-files).
+// При равном времени изменения новее считается файл с большим именем
+// (access-2024-01-07.csv новее access-2024-01-06.csv). Отобранные файлы идут
+// от старых к новым, чтобы записи читались в хронологическом порядке.
+// URL, топики Kafka и сокеты не отбираются и остаются в конце списка.
+func newestFiles(names []string, n int) ([]string, error) {
+	type candidate struct {
+		name    string
+		modTime time.Time
+	}
+	var files []candidate
+	var others []string
+	for _, name := range names {
+		if isHTTPURL(name) || isKafkaInput(name) || isSocketInput(name) {
+			others = append(others, name)
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, candidate{name, info.ModTime()})
+	}
+
+	slices.SortFunc(files, func(a, b candidate) int {
+		if c := a.modTime.Compare(b.modTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	files = files[max(0, len(files)-n):]
+
+	result := make([]string, 0, len(files)+len(others))
+	for _, file := range files {
+		result = append(result, file.name)
+	}
+	return append(result, others...), nil
+}
+
+// Чтение с ограничением скорости (--read-rate): на каждый прочитанный байт нужен
+// токен из limiter, при их нехватке Read ждет. Ожидание прерывается отменой контекста.
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// Ограничиваем чтение из r скоростью bytesPerSecond байт в секунду.
+// Запас токенов — одна секунда чтения: после паузы читатель не обгоняет лимит
+// больше, чем на секунду.
+func newRateLimitedReader(ctx context.Context, r io.Reader, bytesPerSecond int) *rateLimitedReader {
+	return &rateLimitedReader{
+		ctx:     ctx,
+		r:       r,
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond),
+	}
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	// WaitN не выдает за раз больше запаса токенов, поэтому и читаем не больше
+	if len(p) > l.limiter.Burst() {
+		p = p[:l.limiter.Burst()]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.limiter.WaitN(l.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Чтение дописываемого файла (--follow): на конце файла Read не возвращает io.EOF,
+// а ждет interval и читает снова, пока не отменен контекст. Незаконченная последняя
+// строка при этом остается в буфере сканера readLogs, пока не будет дописан ее перевод
+// строки; при отмене чтение заканчивается ошибкой контекста, и фрагмент не разбирается.
+type followReader struct {
+	ctx      context.Context
+	r        io.Reader
+	interval time.Duration
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != io.EOF {
+			return n, err
+		}
+		select {
+		case <-f.ctx.Done():
+			return 0, f.ctx.Err()
+		case <-time.After(f.interval):
+		}
+	}
+}
+
+// Множители единиц для --read-rate (степени 1024)
+var byteUnits = map[string]int{
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+}
+
+// Разбираем значение --read-rate: число с единицей B, KB, MB или GB и необязательным
+// "/s", например 50MB/s (регистр не важен)
+func parseByteRate(value string) (int, error) {
+	s := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "/s")
+	number := strings.TrimRightFunc(s, func(r rune) bool { return r >= 'a' && r <= 'z' })
+	multiplier, ok := byteUnits[s[len(number):]]
+	if !ok {
+		return 0, fmt.Errorf("неизвестная единица в %q (допустимо: B, KB, MB, GB)", value)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("некорректная скорость %q", value)
+	}
+	bytes := int(n * float64(multiplier))
+	if bytes < 1 {
+		return 0, fmt.Errorf("некорректная скорость %q", value)
+	}
+	return bytes, nil
+}
+
+// Проверяем, является ли имя входа URL с протоколом http или https
+func isHTTPURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// Загружаем логи по HTTP с повторами и экспоненциальной паузой между попытками.
+// Повторяем только при сетевых ошибках и ответах 5xx; ответы 4xx возвращаются сразу.
+// Ожидание между попытками прерывается при отмене контекста.
+func fetchHTTP(ctx context.Context, rawURL string, retries int, backoff time.Duration) (io.ReadCloser, error) {
+	delay := backoff
+	for attempt := 0; ; attempt++ {
+		body, retryable, err := httpGet(ctx, rawURL)
+		if err == nil {
+			return body, nil
+		}
+		if !retryable || attempt >= retries {
+			return nil, err
+		}
+
+		log.Printf("ошибка загрузки %s: %v; повтор %d из %d через %v", rawURL, err, attempt+1, retries, delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// Выполняем один GET запрос. Второе значение сообщает, имеет ли смысл повторить запрос.
+func httpGet(ctx context.Context, rawURL string) (io.ReadCloser, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Сетевую ошибку повторяем, если только контекст не отменен
+		return nil, ctx.Err() == nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, resp.StatusCode >= 500, fmt.Errorf("сервер вернул %s", resp.Status)
+	}
+	return resp.Body, false, nil
+}
+
+// Тип сжатия входного файла
+type compression int
+
+const (
+	compressionNone compression = iota
+	compressionGzip
+	compressionBzip2
+	compressionXz
+)
+
+// Название формата сжатия
+func (c compression) String() string {
+	switch c {
+	case compressionGzip:
+		return "gzip"
+	case compressionBzip2:
+		return "bzip2"
+	case compressionXz:
+		return "xz"
+	default:
+		return "none"
+	}
+}
+
+// Сигнатуры (magic bytes) поддерживаемых форматов сжатия
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// Тип сжатия, который обычно означает расширение файла (для --explain: при чтении
+// формат определяется по содержимому)
+func compressionByExt(filename string) compression {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gz":
+		return compressionGzip
+	case ".bz2":
+		return compressionBzip2
+	case ".xz":
+		return compressionXz
+	default:
+		return compressionNone
+	}
+}
+
+// Определяем тип сжатия по первым байтам содержимого
+func compressionByMagic(header []byte) compression {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return compressionGzip
+	case bytes.HasPrefix(header, bzip2Magic):
+		return compressionBzip2
+	case bytes.HasPrefix(header, xzMagic):
+		return compressionXz
+	default:
+		return compressionNone
+	}
+}
+
+// Оборачиваем reader в распаковщик, выбранный по сигнатуре в начале данных.
+// Расширение файла не учитывается: сжатый файл без .gz и несжатый с .gz
+// (после ручной распаковки или переименования) читаются правильно.
+// Несжатые данные возвращаются без изменений.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	// Peek не забирает байты из потока, поэтому они останутся для распаковщика или сканера.
+	// Если данных меньше длины сигнатуры, Peek вернет сколько есть
+	header, _ := br.Peek(len(xzMagic))
+
+	switch compressionByMagic(header) {
+	case compressionGzip:
+		return gzip.NewReader(br)
+	case compressionBzip2:
+		return bzip2.NewReader(br), nil
+	case compressionXz:
+		return xz.NewReader(br)
+	default:
+		return br, nil
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// С --follow незаконченная последняя строка не разбирается, пока ее не допишут:
+// после дописывания она становится обычной записью, а не ошибкой разбора
+func TestFollowTruncatedLastLine(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access.csv")
+	if err := os.WriteFile(name, []byte(testLogsHeader+
+		"2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n"+
+		"2024-01-15 10:30:01,10.0.0.2,GET,/b,20"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	in, err := openInput(ctx, name, InputOptions{FollowInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	var skipped skippedLines
+	entries, err := readLogs(ctx, in, defaultOptions(), &skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func() (LogEntry, bool) {
+		select {
+		case logEntry, ok := <-entries:
+			return logEntry, ok
+		case <-time.After(50 * time.Millisecond):
+			return LogEntry{}, false
+		}
+	}
+
+	if logEntry, ok := receive(); !ok || logEntry.URL != "/a" {
+		t.Fatalf("первая запись %+v, ожидалась /a", logEntry)
+	}
+	if logEntry, ok := receive(); ok {
+		t.Fatalf("незаконченная строка разобрана как %+v", logEntry)
+	}
+
+	// Дописываем конец строки и начало следующей
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("0,15\n2024-01-15 10:30:02,10.0.0.3,GET,/c,50"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	logEntry, ok := receive()
+	want := LogEntry{Timestamp: "2024-01-15 10:30:01", IP: "10.0.0.2", Method: "GET", URL: "/b", StatusCode: 200, ResponseTime: 15}
+	if !ok || logEntry != want {
+		t.Fatalf("дописанная запись %+v, ожидалось %+v", logEntry, want)
+	}
+
+	// После отмены чтение завершается, а незаконченная строка /c так и не разбирается
+	cancel()
+	if rest := collectEntries(entries); len(rest) != 0 {
+		t.Errorf("после отмены получены записи %+v", rest)
+	}
+	if skipped.Total != 0 {
+		t.Errorf("пропущено строк %d, ожидалось 0", skipped.Total)
+	}
+}
+
+// Без новых данных followReader ждет, а после отмены возвращает ошибку контекста
+func TestFollowReaderCancel(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "empty.csv")
+	if err := os.WriteFile(name, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 30*time.Millisecond)
+	defer cancel()
+	n, err := (&followReader{ctx: ctx, r: f, interval: 5 * time.Millisecond}).Read(make([]byte, 16))
+	if n != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Read = %d, %v, ожидалось 0, context.DeadlineExceeded", n, err)
+	}
+}
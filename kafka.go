@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Префикс имени входа для чтения из топика Kafka: kafka://<topic>
+const kafkaScheme = "kafka://"
+
+// Проверяем, является ли имя входа топиком Kafka
+func isKafkaInput(name string) bool {
+	return strings.HasPrefix(name, kafkaScheme)
+}
+
+// Читаем сообщения из топика Kafka как поток строк: значение каждого сообщения —
+// одна строка лога. Смещения коммитятся группой потребителей раз в opts.KafkaCommitInterval.
+// Чтение продолжается, пока не отменен контекст; после отмены поток завершается
+// как обычный конец файла, чтобы pipeline досчитал статистику.
+func openKafka(ctx context.Context, topic string, opts InputOptions) (io.ReadCloser, error) {
+	if len(opts.KafkaBrokers) == 0 {
+		return nil, errors.New("не заданы брокеры Kafka (--kafka-brokers)")
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        opts.KafkaBrokers,
+		Topic:          topic,
+		GroupID:        opts.KafkaGroup,
+		CommitInterval: opts.KafkaCommitInterval,
+	})
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer reader.Close()
+		for {
+			msg, err := reader.ReadMessage(ctx)
+			if err != nil {
+				// Отмена контекста — штатное завершение чтения
+				if ctx.Err() != nil {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := pw.Write(append(msg.Value, '\n')); err != nil {
+				return
+			}
+		}
+	}()
+	return pr, nil
+}
@@ -2,69 +2,291 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"sync"
+	"path"
 )
 
-// Главная функция – точка входа в программу
-func main() {
-	// Создаем контекст с возможностью отмены
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// Код завершения программы, если обработка прервана по --timeout
+const exitCodeTimeout = 3
+
+// Код завершения validate, если во входных данных есть нераспознанные строки
+const exitCodeInvalid = 1
+
+// Код завершения при противоречивых флагах (как у пакета flag при ошибке в флагах)
+const exitCodeUsage = 2
+
+// Подкоманды: имя → обработчик аргументов после имени
+var subcommands = map[string]func(args []string){
+	"analyze":  runAnalyze,
+	"filter":   runFilter,
+	"validate": runValidate,
+}
 
-	// Проверяем аргументы командной строки: ожидаем имя файла с логами
+// Главная функция – точка входа в программу. Первый аргумент выбирает подкоманду;
+// без подкоманды выполняется analyze, как до их появления.
+func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Запуск: go run main.go <logfile.csv>")
+		printUsage()
+		return
+	}
+	if run, ok := subcommands[os.Args[1]]; ok {
+		run(os.Args[2:])
 		return
 	}
+	if os.Args[1] == "help" {
+		printUsage()
+		return
+	}
+	runAnalyze(os.Args[1:])
+}
 
-	// Получаем путь к файлу из аргументов
-	inputFile := os.Args[1]
+// Справка по подкомандам
+func printUsage() {
+	fmt.Println("Запуск: go run . [analyze|filter|validate] [флаги] <logfile.csv | URL>...")
+	fmt.Println("  analyze   статистика по логам (по умолчанию)")
+	fmt.Println("  filter    выгрузка отфильтрованных записей в stdout в формате CSV")
+	fmt.Println("  validate  проверка, что все строки разбираются")
+	fmt.Println("Флаги подкоманды: go run . <подкоманда> -h")
+}
 
-	// Читаем логи из файла (функция из processor.go)
-	logChan, err := readLogs(ctx, inputFile)
-	if err != nil {
+// Подкоманда analyze: статистика по логам (и при необходимости выгрузка через --dump)
+func runAnalyze(args []string) {
+	cfg := newCLIConfig("analyze")
+	cfg.addInputFlags()
+	cfg.addRunFlags()
+	cfg.addFilterFlags()
+	cfg.addStatsFlags()
+	if !cfg.parse(args) {
+		return
+	}
+	if cfg.countOnly {
+		runCount(cfg)
+		return
+	}
+	runProcessing(cfg)
+}
+
+// Режим --count-only: только количество корректных и нераспознанных строк, как можно быстрее
+func runCount(cfg *cliConfig) {
+	ctx, input, stop := cfg.start()
+	defer stop()
+
+	valid, skipped, err := countLogs(ctx, input, cfg.opts)
+	cfg.finishProgress()
+	cfg.reportTiming(valid+skipped.Total, 0)
+	if errors.Is(err, ErrEmptyInput) {
+		fmt.Println("Входные данные пусты, считать нечего")
+		return
+	} else if err != nil && ctx.Err() == nil {
 		log.Fatalf("ошибка чтения логов: %v", err)
 	}
 
-	// Параллельно обрабатываем логи с пулом из 3 воркеров, результат — канал с обработанными логами
-	processedChan := processLogs(ctx, logChan, 3)
+	fmt.Printf("Корректных строк: %s\n", formatCount(valid))
+	fmt.Printf("Строк с ошибками: %s\n", formatCount(skipped.Total))
+	if err != nil {
+		log.Printf("подсчет прерван: %v", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			stop()
+			os.Exit(exitCodeTimeout)
+		}
+	}
+}
 
-	//Формируем filtered и unfiltered буферизованные каналы для предотвращения блокировок при параллельном чтении данных
-	unfilteredChan, filteredChan := tee(processedChan, 100)
+// Подкоманда filter: чтение → отбор записей → выгрузка в stdout, без статистики
+func runFilter(args []string) {
+	cfg := newCLIConfig("filter")
+	cfg.addInputFlags()
+	cfg.addRunFlags()
+	cfg.addFilterFlags()
+	if !cfg.parse(args) {
+		return
+	}
+	cfg.opts.NoStats = true
+	cfg.opts.Dump = os.Stdout
+	runProcessing(cfg)
+}
+
+// Общий запуск analyze и filter через runPipeline
+func runProcessing(cfg *cliConfig) {
+	// Только показываем, что будет сделано, и выходим
+	if cfg.explain {
+		fmt.Println(explainPipeline(cfg.inputFiles, cfg.opts))
+		return
+	}
 
-	// Создаем WaitGroup, чтобы дождаться завершения обеих горутин подсчета статистики
-	var wg sync.WaitGroup
-	wg.Add(2)
+	ctx, input, stop := cfg.start()
+	defer stop()
+	opts := cfg.opts
+
+	// Накопленная статистика прошлых запусков (--state); читаем до обработки,
+	// чтобы поврежденный файл обнаружился сразу
+	var state Statistics
+	if cfg.stateFile != "" {
+		var err error
+		if state, err = loadState(cfg.stateFile); err != nil {
+			log.Fatalf("ошибка чтения файла состояния: %v", err)
+		}
+	}
+
+	// Статистика прошлого запуска для сравнения (--compare)
+	var baseline Statistics
+	if cfg.compareFile != "" {
+		var err error
+		if baseline, err = loadBaseline(cfg.compareFile); err != nil {
+			log.Fatalf("ошибка чтения статистики для сравнения: %v", err)
+		}
+	}
 
-	// Переменные для хранения результатов статистики
+	if !opts.NoStats && cfg.format == "text" {
+		if len(cfg.inputFiles) == 1 {
+			fmt.Println("Имя файла:", path.Base(cfg.inputFiles[0]))
+		} else {
+			fmt.Println("Файлов:", len(cfg.inputFiles))
+		}
+	}
+
+	// Живая статистика для TUI и HTTP сервера статуса: /stats отдает все показатели,
+	// TUI хватает нескольких
+	if (cfg.tui || cfg.statusAddr != "") && !opts.NoStats {
+		liveOpts := opts
+		if cfg.statusAddr == "" {
+			liveOpts = tuiStatsOptions(opts)
+		}
+		opts.Live = newLiveStats(liveOpts)
+	}
+	if opts.Live != nil && cfg.statusAddr != "" {
+		stopServer, err := startStatusServer(ctx, cfg.statusAddr, opts.Live)
+		if err != nil {
+			log.Fatalf("ошибка запуска HTTP сервера статуса: %v", err)
+		}
+		defer stopServer()
+	}
+
+	// Запускаем pipeline обработки (функция из pipeline.go), с --tui — под управлением TUI
 	var stats Statistics
-	var filteredStats Statistics
-
-	// Подсчет статистики по всем логам запускается в отдельной горутине
-	go func() {
-		defer wg.Done()
-		stats = calculateStats(unfilteredChan)
-	}()
-
-	// Фильтруем логи — выбираем только с кодом >= 400 (ошибки)
-	// Подсчитываем статистику по отфильтрованным логам в другой горутине
-	go func() {
-		defer wg.Done()
-		filteredStats = calculateStats(filterLogs(filteredChan, 400)) // Фильтруем и считаем ошибки
-	}()
-
-	// Ждем, пока обе горутины завершатся
-	wg.Wait()
-
-	// Выводим результаты подсчёта
-	fmt.Printf("Всего запросов: %d\n", stats.TotalRequests)
-	fmt.Printf("Всего ошибок (4xx and 5xx): %d\n", filteredStats.ErrorCount)
-	fmt.Printf("Среднее время ответа: %.2f ms\n", stats.AverageRespTime)
-
-	// Выводим топ IP адресов по количеству запросов
-	// В данном случае Топ 5
-	printTopIPs(stats.RequestsByIP, 5)
+	var err error
+	if cfg.tui && !opts.NoStats {
+		title := fmt.Sprintf("%d файлов", len(cfg.inputFiles))
+		if len(cfg.inputFiles) == 1 {
+			title = path.Base(cfg.inputFiles[0])
+		}
+		stats, err = runPipelineWithTUI(ctx, title, opts.Live, func(ctx context.Context) (Statistics, error) {
+			return runPipeline(ctx, input, opts)
+		})
+	} else {
+		stats, err = runPipeline(ctx, input, opts)
+	}
+
+	cfg.finishProgress()
+	if cfg.timing {
+		// Разобранные записи (до фильтров) и нераспознанные строки
+		lines := stats.SkippedLines.Total
+		for _, count := range stats.WorkerCounts {
+			lines += count
+		}
+		cfg.reportTiming(lines, opts.Workers)
+	}
+
+	// Проверяем, не прервана ли обработка по таймауту
+	timedOut := errors.Is(err, context.DeadlineExceeded)
+	if timedOut {
+		log.Printf("превышено время работы (%v), статистика неполная", cfg.timeout)
+	} else if errors.Is(err, context.Canceled) {
+		log.Printf("обработка остановлена, статистика по прочитанным записям")
+	} else if errors.Is(err, ErrEmptyInput) {
+		// Пустой вход — не ошибка: обрабатывать нечего
+		fmt.Println("Входные данные пусты, обрабатывать нечего")
+		return
+	} else if err != nil {
+		log.Fatalf("ошибка обработки логов: %v", err)
+	}
+
+	// Прибавляем запуск к накопленной статистике и сохраняем ее. Прерванный запуск
+	// не сохраняется: иначе повторная обработка тех же файлов учла бы их дважды
+	if cfg.stateFile != "" && !opts.NoStats {
+		stats = mergeStatistics(state, stats)
+		if err != nil {
+			log.Printf("обработка не завершена, файл состояния не обновлен")
+		} else if err := saveStatistics(cfg.stateFile, stats); err != nil {
+			log.Fatalf("ошибка записи файла состояния: %v", err)
+		}
+	}
+
+	// Выводим образцы разобранных записей перед статистикой
+	printEntries("Первые записи", stats.Sample.Head)
+	printEntries("Последние записи", stats.Sample.Tail)
+
+	// Выводим отчет; в режиме фильтра статистика не считается и не выводится
+	if !opts.NoStats {
+		// Разрешаем имена только для IP, которые попадут в отчет
+		if cfg.resolveDNS {
+			opts.Hostnames = resolveHostnames(ctx, reportedIPs(stats, opts, 5))
+		}
+		reportWriters[cfg.format].WriteReport(os.Stdout, stats, opts)
+
+		if cfg.compareFile != "" {
+			printComparison(os.Stdout, path.Base(cfg.compareFile), baseline, stats)
+		}
+		if cfg.saveStatsFile != "" {
+			if err := saveStatistics(cfg.saveStatsFile, stats); err != nil {
+				log.Fatalf("ошибка записи статистики: %v", err)
+			}
+		}
+
+		// Та же статистика в формате OpenMetrics; файл заменяется атомарно,
+		// чтобы node_exporter не прочитал его наполовину записанным
+		if cfg.openMetricsOut != "" {
+			if err := writeFileAtomic(cfg.openMetricsOut, formatOpenMetrics(stats, opts)); err != nil {
+				log.Fatalf("ошибка записи метрик: %v", err)
+			}
+		}
+		if cfg.heatmapOut != "" {
+			if err := writeFileAtomic(cfg.heatmapOut, formatHeatmapCSV(stats.RequestsByWeekdayHour)); err != nil {
+				log.Fatalf("ошибка записи тепловой карты: %v", err)
+			}
+		}
+	}
+
+	// При таймауте завершаемся с отдельным кодом, чтобы cron мог его отличить
+	if timedOut {
+		stop()
+		os.Exit(exitCodeTimeout)
+	}
+}
+
+// Подкоманда validate: разбираем все строки и сообщаем, сколько из них не разобрано.
+// Нераспознанные строки выводятся в лог (и в --rejects); если они есть, код завершения — 1.
+func runValidate(args []string) {
+	cfg := newCLIConfig("validate")
+	cfg.addInputFlags()
+	cfg.addRunFlags()
+	if !cfg.parse(args) {
+		return
+	}
+
+	ctx, input, stop := cfg.start()
+	defer stop()
+
+	valid, skipped, err := countLogs(ctx, input, cfg.opts)
+	cfg.finishProgress()
+	cfg.reportTiming(valid+skipped.Total, 0)
+	if errors.Is(err, ErrEmptyInput) {
+		fmt.Println("Входные данные пусты, проверять нечего")
+		return
+	} else if err != nil && ctx.Err() == nil {
+		log.Fatalf("ошибка чтения логов: %v", err)
+	}
+
+	fmt.Printf("Корректных строк: %s\n", formatCount(valid))
+	fmt.Printf("Строк с ошибками: %s\n", skipped)
+	if err != nil {
+		log.Printf("проверка прервана: %v", err)
+	}
+	if skipped.Total > 0 {
+		stop()
+		os.Exit(exitCodeInvalid)
+	}
 }
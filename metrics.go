@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Префикс имен метрик в выгрузке OpenMetrics
+const metricsPrefix = "logproc_"
+
+// Итоговая статистика в текстовом формате OpenMetrics (совместим с textfile
+// collector у node_exporter). Выводятся только вычисленные показатели;
+// метки отсортированы, чтобы файл не менялся без изменения данных.
+func formatOpenMetrics(stats Statistics, opts Options) []byte {
+	var buf bytes.Buffer
+
+	writeMetric(&buf, "requests", "counter", "Общее количество запросов", stats.TotalRequests)
+	if opts.Stats.has(statErrors) {
+		writeMetric(&buf, "errors", "counter", fmt.Sprintf("Количество ошибок (%s)", errorDefinition(opts)), stats.ErrorCount)
+	}
+	if stats.RequestsByStatus != nil {
+		help := "Количество ответов по кодам статуса"
+		if opts.StatusByClass {
+			help = "Количество ответов по классам статусов"
+		}
+		writeMetricHeader(&buf, "responses", "counter", help)
+		for _, status := range slices.Sorted(maps.Keys(stats.RequestsByStatus)) {
+			fmt.Fprintf(&buf, "%sresponses_total{status=%q} %d\n", metricsPrefix, statusLabel(status, opts.StatusByClass), stats.RequestsByStatus[status])
+		}
+	}
+	if stats.RequestsByMethod != nil {
+		writeMetricHeader(&buf, "method_requests", "counter", "Количество запросов по HTTP методам")
+		for _, method := range slices.Sorted(maps.Keys(stats.RequestsByMethod)) {
+			fmt.Fprintf(&buf, "%smethod_requests_total{method=%s} %d\n", metricsPrefix, quoteLabel(method), stats.RequestsByMethod[method])
+		}
+	}
+	if opts.Stats.has(statAvgTime) {
+		writeMetric(&buf, "response_time_average_seconds", "gauge", "Среднее время ответа", stats.AverageRespTime/1000)
+	}
+	if opts.Stats.has(statPeakRate) {
+		writeMetric(&buf, "peak_requests_per_second", "gauge", "Наибольшее количество запросов за одну секунду", stats.PeakRate)
+	}
+	if opts.Stats.has(statTimeSpan) && !stats.FirstTimestamp.IsZero() {
+		writeMetric(&buf, "first_timestamp_seconds", "gauge", "Время самой ранней записи", stats.FirstTimestamp.Unix())
+		writeMetric(&buf, "last_timestamp_seconds", "gauge", "Время самой поздней записи", stats.LastTimestamp.Unix())
+	}
+
+	buf.WriteString("# EOF\n")
+	return buf.Bytes()
+}
+
+// Заголовок семейства метрик: тип и описание
+func writeMetricHeader(buf *bytes.Buffer, name, metricType, help string) {
+	fmt.Fprintf(buf, "# TYPE %s%s %s\n", metricsPrefix, name, metricType)
+	fmt.Fprintf(buf, "# HELP %s%s %s\n", metricsPrefix, name, help)
+}
+
+// Семейство из одной метрики без меток. У счетчиков к имени добавляется суффикс _total
+func writeMetric(buf *bytes.Buffer, name, metricType, help string, value any) {
+	writeMetricHeader(buf, name, metricType, help)
+	sample := metricsPrefix + name
+	if metricType == "counter" {
+		sample += "_total"
+	}
+	fmt.Fprintf(buf, "%s %v\n", sample, value)
+}
+
+// Значение метки в кавычках с экранированием \, " и перевода строки
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
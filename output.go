@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Атомарная запись файла: данные пишутся во временный файл в том же каталоге,
+// который затем переименовывается в path. Читатели видят либо старый файл, либо новый целиком.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Если что-то пошло не так, временный файл удаляем (после rename удалять уже нечего)
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
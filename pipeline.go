@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Настройки pipeline обработки логов
+type Options struct {
+	Workers         int            // количество воркеров в пуле processLogs
+	ErrorStatus     int            // минимальный код ответа, который считается ошибкой
+	ErrorCodes      map[int]bool   // коды ответа, которые считаются ошибкой (если заданы — вместо ErrorStatus)
+	NormalizeMethod bool           // приводить HTTP метод к верхнему регистру
+	TeeBufferSize   int            // размер буфера каналов после разветвления tee
+	TeeSpill        bool           // выгружать на диск записи, которые не помещаются в буфер ветви после tee
+	TeeSpillDir     string         // каталог для файлов выгрузки (пусто — временный каталог системы)
+	Rejects         io.Writer      // куда записывать нераспознанные строки (nil — никуда)
+	WorkerStats     bool           // считать количество записей, обработанных каждым воркером
+	Stats           statsSelection // какие показатели вычислять
+	Dump            io.Writer      // куда выгружать отфильтрованные записи (nil — не выгружать)
+	NoStats         bool           // не считать статистику, только фильтровать и выгружать
+	DecodeURLs      bool           // раскодировать percent-encoding в пути URL
+	TopEndpoints    int            // сколько эндпоинтов выводить в рейтинге
+	Verbose         bool           // подробный отчет (разбивка URL по классам статусов)
+	TimeUnit        timeUnit       // единица времени ответа во входных данных
+	Schema          *logSchema     // явно заданная схема (nil — определять по заголовку)
+	AutoHeader      bool           // определять наличие заголовка по содержимому первой строки
+	ReadRate        int            // ограничение скорости чтения входных данных, байт/с (0 — без ограничения)
+
+	// Допускать лишние колонки в конце строки (--allow-extra-fields): они отбрасываются
+	AllowExtraFields bool
+
+	// Не очищать значения колонок от пробелов по краям (--no-trim)
+	NoTrim bool
+
+	// Считать записи по воркерам, не выводя их в отчете (для --timing: сумма
+	// Statistics.WorkerCounts — количество разобранных записей до фильтров)
+	CountWorkers bool
+
+	// Вывести в stderr схему, по которой разбираются строки (--print-schema)
+	PrintSchema bool
+
+	// Начало записи для многострочных записей (--multiline): строки, которые не
+	// соответствуют выражению, продолжают предыдущую запись (nil — запись в каждой строке)
+	RecordStart *regexp.Regexp
+
+	RollupDir      string        // каталог для почасовых сводок (пусто — не писать)
+	RollupInterval time.Duration // как часто перезаписывать изменившиеся сводки
+
+	// Файл базы SQLite, в таблицу logs которой загружаются записи (пусто — не загружать),
+	// и сколько записей вставлять в одной транзакции
+	SQLitePath  string
+	SQLiteBatch int
+
+	// Сколько первых и последних разобранных записей сохранить для вывода
+	Head int
+	Tail int
+
+	// Количество параллельных накопителей статистики (записи делятся по хешу IP)
+	StatsShards int
+
+	// Обрабатывать только записи этого вида (пусто — все): success, redirects, errors
+	Only string
+
+	// Условия фильтра для выгрузки (--dump), объединяются по И
+	FilterMinStatus int    // минимальный код ответа
+	FilterMethod    string // HTTP метод (пусто — любой)
+
+	// Регулярные выражения для URL: обрабатываются только записи, URL которых
+	// соответствует URLPattern и не соответствует URLExclude (nil — без условия)
+	URLPattern *regexp.Regexp
+	URLExclude *regexp.Regexp
+
+	// Обезличивать IP до всех остальных стадий (--anonymize-ip, --ip-hash; nil — не обезличивать)
+	AnonymizeIP *ipAnonymizer
+
+	// Допустимый диапазон времени ответа в ms (--min-response-time, --max-response-time):
+	// записи вне него отбрасываются как выбросы (±Inf — граница не задана)
+	MinResponseTime float64
+	MaxResponseTime float64
+
+	// Оставлять только записи за последний период Since (0 — все записи),
+	// отсчитанный от самой поздней записи ("max") или от текущего времени ("now")
+	Since      time.Duration
+	RelativeTo string
+
+	// Журнал ошибок для сопоставления с ответами 5xx (nil — не сопоставлять)
+	// и наибольшая разница во времени между ответом и сообщением
+	ErrorLog       errorLog
+	ErrorLogWindow time.Duration
+
+	// Длина интервала для поиска пропусков в логах: интервалы без запросов между первой
+	// и последней записью (0 — не искать)
+	GapInterval time.Duration
+
+	// Считать запросы по дням недели и часам суток для тепловой карты (--heatmap-out)
+	Heatmap bool
+
+	// Поиск всплесков нагрузки: интервалы длиной SpikeInterval, в которых запросов больше
+	// скользящей медианы в SpikeFactor раз (0 — не искать)
+	SpikeFactor   float64
+	SpikeInterval time.Duration
+
+	// Цели SLO по группам URL (--slo-config): для каждой считается перцентиль
+	// времени ответа (nil — не проверять)
+	SLOs []sloTarget
+
+	// Порог аномальной задержки в стандартных отклонениях от среднего (0 — не искать аномалии)
+	AnomalySigma float64
+
+	// Пороги времени ответа: медленные запросы — дольше SlowThreshold, быстрые — быстрее
+	// FastThreshold (0 — не считать)
+	SlowThreshold time.Duration
+	FastThreshold time.Duration
+
+	// Считать RequestsByStatus по классам статусов (--status-granularity=class): ключ —
+	// первый код класса (200 для 2xx), 0 — коды вне диапазона 100–599
+	StatusByClass bool
+
+	// Сторож зависания (--stall-timeout): считает записи перед подсчетом статистики
+	// и выгрузкой (nil — не следить)
+	Watchdog *stallWatchdog
+
+	// Живая статистика для TUI и --status-addr (nil — не нужна): учитывает записи перед подсчетом статистики
+	Live *liveStats
+
+	// Выдавать записи после пула воркеров в исходном порядке (для воспроизводимой выгрузки)
+	PreserveOrder bool
+
+	// Имена хостов для IP из рейтингов отчета (--resolve-dns): заполняются после
+	// обработки, перед выводом отчета (nil — IP выводятся без имен)
+	Hostnames map[string]string
+
+	// Рейтинг, который выводится вместо топ IP и топ URL (пусто — оба как раньше),
+	// одно из topByValues
+	TopBy string
+
+	// Параметр query string, по значениям которого считается RequestsByParam (пусто — не считать)
+	GroupByParam string
+
+	// Количество первых сегментов пути, по которым группируется RequestsByURL
+	// (0 — группировка по полному пути)
+	URLPrefixSegments int
+}
+
+// Начало записи для --multiline по умолчанию: строка начинается со времени записи
+// в формате timestampLayout ("2024-01-15 10:30:00")
+var defaultRecordStart = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}`)
+
+// Настройки по умолчанию.
+// Количество воркеров по умолчанию равно числу CPU: воркеры не ждут ввода-вывода,
+// поэтому больше воркеров, чем ядер, не ускоряет обработку, а меньше — оставляет ядра без работы.
+func defaultOptions() Options {
+	return Options{
+		Workers:         runtime.NumCPU(),
+		ErrorStatus:     400,
+		NormalizeMethod: true,
+		TeeBufferSize:   100,
+		Stats:           statDefault,
+		TopEndpoints:    5,
+		TimeUnit:        unitMilliseconds,
+		FilterMinStatus: 400,
+		RollupInterval:  10 * time.Second,
+		SQLiteBatch:     1000,
+		StatsShards:     1,
+		RelativeTo:      "max",
+		ErrorLogWindow:  5 * time.Second,
+		SpikeInterval:   time.Minute,
+		MinResponseTime: math.Inf(-1),
+		MaxResponseTime: math.Inf(1),
+	}
+}
+
+// Настройки для вызова pipeline из кода (Analyze, NewPipeline, CalculateStatsSlice):
+// поля, нулевое значение которых не имеет смысла (нет воркеров, не выбран ни один
+// показатель, пустой диапазон времени ответа и т.д.), заполняются из defaultOptions.
+// Поля, где ноль означает "выключено" (--anomaly-sigma, --head и другие), не меняются.
+func withDefaults(opts Options) Options {
+	defaults := defaultOptions()
+	if opts.Workers < 1 {
+		opts.Workers = defaults.Workers
+	}
+	if opts.Stats == 0 {
+		opts.Stats = defaults.Stats
+	}
+	if opts.StatsShards < 1 {
+		opts.StatsShards = defaults.StatsShards
+	}
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = defaults.ErrorStatus
+	}
+	if opts.TopEndpoints == 0 {
+		opts.TopEndpoints = defaults.TopEndpoints
+	}
+	if opts.TimeUnit == "" {
+		opts.TimeUnit = defaults.TimeUnit
+	}
+	if opts.RollupInterval <= 0 {
+		opts.RollupInterval = defaults.RollupInterval
+	}
+	if opts.SQLiteBatch < 1 {
+		opts.SQLiteBatch = defaults.SQLiteBatch
+	}
+	if opts.RelativeTo == "" {
+		opts.RelativeTo = defaults.RelativeTo
+	}
+	if opts.SpikeInterval <= 0 {
+		opts.SpikeInterval = defaults.SpikeInterval
+	}
+	if opts.MinResponseTime == 0 && opts.MaxResponseTime == 0 {
+		opts.MinResponseTime, opts.MaxResponseTime = defaults.MinResponseTime, defaults.MaxResponseTime
+	}
+	return opts
+}
+
+// Функция runPipeline собирает и запускает весь pipeline:
+// чтение → обработка → подсчет статистики (ошибки считаются в том же проходе).
+// Если нужна выгрузка ошибок (opts.Dump), поток разветвляется через tee:
+// одна ветвь считает статистику, другая фильтрует и выгружает записи.
+// Если контекст отменен, возвращается частичная статистика и ошибка контекста.
+func runPipeline(ctx context.Context, r io.Reader, opts Options) (Statistics, error) {
+	// Базу SQLite открываем до чтения, чтобы ошибка обнаружилась сразу. Закрывается
+	// она после окончания pipeline: к этому моменту стадия загрузки уже зафиксировала данные
+	var db *sql.DB
+	if opts.SQLitePath != "" {
+		var err error
+		if db, err = openSQLite(opts.SQLitePath); err != nil {
+			return Statistics{}, fmt.Errorf("ошибка открытия базы SQLite: %w", err)
+		}
+		defer db.Close()
+	}
+
+	// Читаем логи (функция из processor.go)
+	var skipped skippedLines
+	logChan, err := readLogs(ctx, r, opts, &skipped)
+	if err != nil {
+		return Statistics{}, err
+	}
+
+	// Обезличиваем IP сразу после разбора: ни образцы, ни выгрузка, ни статистика
+	// не видят исходных адресов
+	if opts.AnonymizeIP != nil {
+		logChan = anonymizeIPs(logChan, opts.AnonymizeIP)
+	}
+
+	// Сохраняем образцы записей сразу после разбора, пока порядок совпадает с файлом
+	var sample logSample
+	if opts.Head > 0 || opts.Tail > 0 {
+		logChan = sampleLogs(logChan, opts.Head, opts.Tail, &sample)
+	}
+
+	// Параллельно обрабатываем логи пулом воркеров, результат — канал с обработанными логами
+	var workerCounts []int
+	if opts.WorkerStats || opts.CountWorkers {
+		workerCounts = make([]int, opts.Workers)
+	}
+	processedChan := processLogs(ctx, logChan, opts.Workers, workerCounts, opts.PreserveOrder)
+
+	// Приводим HTTP методы к верхнему регистру
+	if opts.NormalizeMethod {
+		processedChan = normalizeMethods(processedChan)
+	}
+
+	// Раскодируем URL, чтобы /search%20a и /search a считались одним URL
+	var urlDecodeErrors int
+	if opts.DecodeURLs {
+		processedChan = decodeURLs(processedChan, &urlDecodeErrors)
+	}
+
+	// Отбрасываем записи с неправдоподобным временем ответа, чтобы они не искажали
+	// среднее (--min-response-time, --max-response-time)
+	var outliers int
+	if hasResponseTimeRange(opts) {
+		processedChan = responseTimeFilter(processedChan, opts, &outliers)
+	}
+
+	// Оставляем только записи выбранного вида (--only)
+	if opts.Only != "" {
+		processedChan = filterLogs(processedChan, onlyFilter(opts))
+	}
+
+	// Оставляем только записи с подходящими URL (--url-pattern, --url-pattern-invert)
+	if opts.URLPattern != nil || opts.URLExclude != nil {
+		processedChan = filterLogs(processedChan, urlFilter(opts))
+	}
+
+	// Оставляем только записи за последний период (--since)
+	if opts.Since > 0 {
+		processedChan = sinceFilter(processedChan, opts)
+	}
+
+	// Почасовые сводки пишутся по мере поступления данных
+	if opts.RollupDir != "" {
+		processedChan = rollupLogs(processedChan, opts.RollupDir, opts.RollupInterval, errorFilter(opts))
+	}
+
+	// Записи загружаются в SQLite по мере поступления данных
+	if db != nil {
+		processedChan = sqliteLogs(processedChan, db, opts.SQLiteBatch)
+	}
+
+	// Живые счетчики для TUI видят те же записи, что и подсчет статистики
+	if opts.Live != nil {
+		processedChan = opts.Live.watch(processedChan)
+	}
+
+	// Сторож считает записи, дошедшие до потребителей: если они перестали приходить,
+	// какая-то стадия зависла
+	if opts.Watchdog != nil {
+		processedChan = opts.Watchdog.count(processedChan)
+	}
+
+	// Режим фильтра: без статистики и без tee, pipeline сводится к чтение → фильтр → выгрузка
+	if opts.NoStats {
+		drain(dumpFiltered(processedChan, opts))
+		return Statistics{Sample: sample, SkippedLines: skipped, WorkerCounts: workerCounts}, ctx.Err()
+	}
+
+	var stats Statistics
+	if opts.Dump == nil {
+		// Единственный потребитель — подсчет статистики, разветвление не нужно
+		stats = calculateStatsSharded(processedChan, opts, opts.StatsShards)
+	} else {
+		//Формируем буферизованные каналы для статистики и выгрузки, чтобы ветви не блокировали друг друга
+		statsChan, dumpChan := tee(processedChan, opts.TeeBufferSize)
+
+		// С выгрузкой на диск отстающая ветвь не тормозит tee, а память ограничена буфером
+		if opts.TeeSpill {
+			statsChan = spillQueue(statsChan, opts.TeeBufferSize, opts.TeeSpillDir)
+			dumpChan = spillQueue(dumpChan, opts.TeeBufferSize, opts.TeeSpillDir)
+		}
+
+		// Выгрузка ошибок идет в отдельной горутине, WaitGroup дожидается ее завершения
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drain(dumpFiltered(dumpChan, opts))
+		}()
+
+		stats = calculateStatsSharded(statsChan, opts, opts.StatsShards)
+		wg.Wait()
+	}
+
+	// Все воркеры к этому моменту завершились, счетчики можно читать
+	stats.WorkerCounts = workerCounts
+	stats.URLDecodeErrors = urlDecodeErrors
+	stats.ResponseTimeOutliers = outliers
+	stats.SkippedLines = skipped
+	stats.Sample = sample
+
+	return stats, ctx.Err()
+}
+
+// Кратчайший pipeline: чтение → разбор → подсчет (--count-only, validate). Без воркеров,
+// фильтров, tee и статистики: возвращает только количество корректных записей и
+// нераспознанные строки по причинам.
+func countLogs(ctx context.Context, r io.Reader, opts Options) (valid int, skipped skippedLines, err error) {
+	logChan, err := readLogs(ctx, r, opts, &skipped)
+	if err != nil {
+		return 0, skipped, err
+	}
+	if opts.Watchdog != nil {
+		logChan = opts.Watchdog.count(logChan)
+	}
+	for range logChan {
+		valid++
+	}
+	return valid, skipped, ctx.Err()
+}
+
+// Фильтрация записей по условиям из opts и их выгрузка в opts.Dump, если она задана
+func dumpFiltered(input <-chan LogEntry, opts Options) <-chan LogEntry {
+	filtered := filterLogs(input, logFilter(opts))
+	if opts.Dump != nil {
+		filtered = dumpLogs(filtered, opts.Dump)
+	}
+	return filtered
+}
+
+// Условие фильтра из настроек: все заданные условия проверяются за один проход
+func logFilter(opts Options) logPredicate {
+	predicates := []logPredicate{statusAtLeast(opts.FilterMinStatus)}
+	if opts.FilterMethod != "" {
+		predicates = append(predicates, methodIs(opts.FilterMethod))
+	}
+	return allOf(predicates...)
+}
+
+// Допустимые значения --top-by
+var topByValues = []string{"ip-requests", "url-requests", "url-errors", "ip-bytes", "url-latency", "referer", "user-agent"}
+
+// Рейтинг для --top-by: заголовок, единица значений и значения по ключам.
+// Для url-latency значение — среднее время ответа по URL, округленное до ms.
+func topByRanking(stats Statistics, topBy string) (title, unit string, values map[string]int) {
+	switch topBy {
+	case "url-requests":
+		return "URL по количеству запросов", "запросов", stats.RequestsByURL
+	case "url-errors":
+		return "URL по количеству ошибок", "ошибок", stats.ErrorsByURL
+	case "ip-bytes":
+		return "IP по объему ответов", "байт", stats.BytesByIP
+	case "url-latency":
+		averages := make(map[string]int, len(stats.RespTimeByURL))
+		for url, total := range stats.RespTimeByURL {
+			if count := stats.RequestsByURL[url]; count > 0 {
+				averages[url] = int(math.Round(total / float64(count)))
+			}
+		}
+		return "URL по среднему времени ответа", "ms", averages
+	case "referer":
+		return "Referer по количеству запросов", "запросов", stats.RequestsByReferer
+	case "user-agent":
+		return "User-Agent по количеству запросов", "запросов", stats.RequestsByUA
+	default:
+		return "IP по количеству запросов", "запросов", stats.RequestsByIP
+	}
+}
+
+// Допустимые значения --only
+var onlyValues = []string{"success", "redirects", "errors"}
+
+// Условие для --only: успешные ответы (2xx), перенаправления (3xx) или ошибки
+func onlyFilter(opts Options) logPredicate {
+	switch opts.Only {
+	case "success":
+		return statusClassIs(2)
+	case "redirects":
+		return statusClassIs(3)
+	default:
+		return errorFilter(opts)
+	}
+}
+
+// Условие ошибки: код ответа из набора --error-codes, если он задан,
+// иначе код не меньше ErrorStatus
+func errorFilter(opts Options) logPredicate {
+	if len(opts.ErrorCodes) > 0 {
+		return statusIn(opts.ErrorCodes)
+	}
+	return statusAtLeast(opts.ErrorStatus)
+}
+
+// Описание того, что считается ошибкой, для отчета и метрик: "статус >= 400"
+// или "статус 500, 502, 503"
+func errorDefinition(opts Options) string {
+	if len(opts.ErrorCodes) == 0 {
+		return fmt.Sprintf("статус >= %d", opts.ErrorStatus)
+	}
+	codes := slices.Sorted(maps.Keys(opts.ErrorCodes))
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = strconv.Itoa(code)
+	}
+	return "статус " + strings.Join(parts, ", ")
+}
+
+// Разбираем значение --error-codes: коды ответа через запятую, например 500,502,503
+func parseErrorCodes(value string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, part := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("некорректный код ответа: %q", part)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
+
+// Условие для --url-pattern и --url-pattern-invert (объединяются по И)
+func urlFilter(opts Options) logPredicate {
+	var predicates []logPredicate
+	if opts.URLPattern != nil {
+		predicates = append(predicates, urlMatches(opts.URLPattern))
+	}
+	if opts.URLExclude != nil {
+		predicates = append(predicates, not(urlMatches(opts.URLExclude)))
+	}
+	return allOf(predicates...)
+}
+
+// Задана ли хотя бы одна граница времени ответа
+func hasResponseTimeRange(opts Options) bool {
+	return !math.IsInf(opts.MinResponseTime, -1) || !math.IsInf(opts.MaxResponseTime, 1)
+}
+
+// Диапазон времени ответа для отчета и --explain, например "0ms..60000ms" или ">=0ms"
+func describeResponseTimeRange(opts Options) string {
+	switch {
+	case math.IsInf(opts.MaxResponseTime, 1):
+		return ">=" + formatMillis(opts.MinResponseTime) + "ms"
+	case math.IsInf(opts.MinResponseTime, -1):
+		return "<=" + formatMillis(opts.MaxResponseTime) + "ms"
+	default:
+		return formatMillis(opts.MinResponseTime) + "ms.." + formatMillis(opts.MaxResponseTime) + "ms"
+	}
+}
+
+// Стадия для --min-response-time и --max-response-time: пропускаем записи со временем
+// ответа в диапазоне, остальные отбрасываем и считаем в outliers
+func responseTimeFilter(input <-chan LogEntry, opts Options, outliers *int) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		dropped := 0
+		defer func() { *outliers = dropped }()
+
+		for logEntry := range input {
+			if logEntry.ResponseTime < opts.MinResponseTime || logEntry.ResponseTime > opts.MaxResponseTime {
+				dropped++
+				continue
+			}
+			out <- logEntry
+		}
+	}()
+
+	return out
+}
+
+// Допустимые значения --relative-to
+var relativeToValues = []string{"max", "now"}
+
+// Стадия для --since. От текущего времени граница вычисляется один раз при запуске;
+// время в логах без часового пояса, поэтому текущее время берется по местным часам.
+func sinceFilter(input <-chan LogEntry, opts Options) <-chan LogEntry {
+	if opts.RelativeTo == "now" {
+		now, _ := time.Parse(timestampLayout, time.Now().Format(timestampLayout))
+		return filterLogs(input, timestampAtLeast(now.Add(-opts.Since)))
+	}
+	return sinceLatest(input, opts.Since)
+}
+
+// Описание фильтра для --explain, например "filter(status>=400,method=POST)"
+func describeFilter(opts Options) string {
+	conditions := []string{fmt.Sprintf("status>=%d", opts.FilterMinStatus)}
+	if opts.FilterMethod != "" {
+		conditions = append(conditions, "method="+opts.FilterMethod)
+	}
+	return "filter(" + strings.Join(conditions, ",") + ")"
+}
+
+// Дочитываем канал до конца, чтобы все предыдущие стадии завершились
+func drain(input <-chan LogEntry) {
+	for range input {
+	}
+}
+
+// Функция explainPipeline описывает стадии, которые runPipeline построит для
+// заданных входов и настроек, в виде строки "read(file) → process(workers=4) → ...".
+// Ветви после tee разделяются символом " | ". Порядок стадий должен совпадать с runPipeline.
+func explainPipeline(inputs []string, opts Options) string {
+	stages := []string{describeInputs(inputs)}
+	if opts.AnonymizeIP != nil {
+		stages = append(stages, opts.AnonymizeIP.stage)
+	}
+	if opts.Head > 0 || opts.Tail > 0 {
+		stages = append(stages, fmt.Sprintf("sample(head=%d,tail=%d)", opts.Head, opts.Tail))
+	}
+	if opts.PreserveOrder {
+		stages = append(stages, fmt.Sprintf("process(workers=%d,ordered)", opts.Workers))
+	} else {
+		stages = append(stages, fmt.Sprintf("process(workers=%d)", opts.Workers))
+	}
+	if opts.NormalizeMethod {
+		stages = append(stages, "normalize-methods")
+	}
+	if opts.DecodeURLs {
+		stages = append(stages, "decode-urls")
+	}
+	if hasResponseTimeRange(opts) {
+		stages = append(stages, "response-time("+describeResponseTimeRange(opts)+")")
+	}
+	if opts.Only != "" {
+		stages = append(stages, "only("+opts.Only+")")
+	}
+	if opts.URLPattern != nil || opts.URLExclude != nil {
+		var conditions []string
+		if opts.URLPattern != nil {
+			conditions = append(conditions, "url~"+opts.URLPattern.String())
+		}
+		if opts.URLExclude != nil {
+			conditions = append(conditions, "url!~"+opts.URLExclude.String())
+		}
+		stages = append(stages, "url("+strings.Join(conditions, ",")+")")
+	}
+	if opts.Since > 0 {
+		stages = append(stages, fmt.Sprintf("since(%v,relative-to=%s)", opts.Since, opts.RelativeTo))
+	}
+	if opts.RollupDir != "" {
+		stages = append(stages, "rollup("+opts.RollupDir+")")
+	}
+	if opts.SQLitePath != "" {
+		stages = append(stages, fmt.Sprintf("sqlite(%s,batch=%d)", opts.SQLitePath, opts.SQLiteBatch))
+	}
+
+	// Ветвь с фильтрацией и выгрузкой
+	filtered := []string{describeFilter(opts)}
+	if opts.Dump != nil {
+		filtered = append(filtered, "dump")
+	}
+
+	if opts.NoStats {
+		stages = append(stages, filtered...)
+		return strings.Join(stages, " → ")
+	}
+
+	statsStage := fmt.Sprintf("stats(%s)", opts.Stats)
+	if opts.StatsShards > 1 {
+		statsStage = fmt.Sprintf("stats(%s,shards=%d)", opts.Stats, opts.StatsShards)
+	}
+	if opts.Dump == nil {
+		stages = append(stages, statsStage)
+		return strings.Join(stages, " → ")
+	}
+	if opts.TeeSpill {
+		stages = append(stages, fmt.Sprintf("tee(buffer=%d,spill)", opts.TeeBufferSize))
+	} else {
+		stages = append(stages, fmt.Sprintf("tee(buffer=%d)", opts.TeeBufferSize))
+	}
+	return strings.Join(stages, " → ") + " → " + statsStage + " | " + strings.Join(filtered, " → ")
+}
+
+// Описание стадии чтения: источник и сжатие (по расширению файла)
+func describeInputs(inputs []string) string {
+	if len(inputs) != 1 {
+		return fmt.Sprintf("read(%d inputs)", len(inputs))
+	}
+
+	source := "file"
+	switch {
+	case isHTTPURL(inputs[0]):
+		source = "http"
+	case isKafkaInput(inputs[0]):
+		source = "kafka"
+	}
+	if kind := compressionByExt(inputs[0]); kind != compressionNone {
+		source += "," + kind.String()
+	}
+	return "read(" + source + ")"
+}
+
+// Функция Analyze — простой синхронный вызов pipeline: читает логи из r целиком
+// и возвращает статистику по всем записям. Внутри используются те же конкурентные
+// стадии, что и в runPipeline, но каналы наружу не передаются. Незаполненные поля
+// opts (например, Options{}) берутся из настроек по умолчанию, см. withDefaults.
+// Пакет main нельзя импортировать, поэтому Analyze вызывается только из кода
+// этого пакета (и из его тестов); для использования снаружи его нужно вынести в пакет.
+func Analyze(r io.Reader, opts Options) (Statistics, error) {
+	return runPipeline(context.Background(), r, withDefaults(opts))
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// Логи из testdata/logs.csv: 15 записей, 8 ошибок (4xx и 5xx)
+func openTestLogs(t testing.TB) *os.File {
+	t.Helper()
+	f, err := os.Open("testdata/logs.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestAnalyzeZeroOptions(t *testing.T) {
+	stats, err := Analyze(openTestLogs(t), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 15 {
+		t.Errorf("TotalRequests = %d, ожидалось 15", stats.TotalRequests)
+	}
+	if stats.RequestsByIP == nil || stats.AverageRespTime == 0 {
+		t.Errorf("с Options{} показатели не посчитаны: %+v", stats)
+	}
+}
+
+func TestPipelineWithZeroOptions(t *testing.T) {
+	stats, err := NewPipeline(strings.NewReader(testLogsHeader + "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n")).
+		WithOptions(Options{}).
+		Workers(2).
+		Collect(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalRequests != 1 || stats.RequestsByURL["/a"] != 1 {
+		t.Errorf("статистика = %d запросов, %v, ожидался 1 запрос к /a", stats.TotalRequests, stats.RequestsByURL)
+	}
+}
+
+// Весь pipeline на testdata/logs.csv: точная статистика без отбора и с отбором по URL
+// и выгрузка ошибок из отобранных записей
+func TestRunPipelineLogs(t *testing.T) {
+	tests := []struct {
+		name       string
+		urlPattern string
+		want       Statistics
+		wantDumped string
+	}{
+		{
+			name: "без отбора",
+			want: Statistics{
+				TotalRequests:   15,
+				ErrorCount:      8,
+				AverageRespTime: 9980.0 / 15,
+				RequestsByIP: map[string]int{
+					"192.168.1.100": 5, "192.168.1.101": 1, "192.168.1.102": 2, "192.168.1.103": 1, "192.168.1.104": 1,
+					"192.168.1.105": 1, "192.168.1.106": 1, "192.168.1.107": 1, "192.168.1.108": 1, "192.168.1.109": 1,
+				},
+				RequestsByURL: map[string]int{
+					"/api/users": 2, "/api/users/123": 1, "/api/products": 1, "/api/orders": 2, "/api/health": 1,
+					"/api/reports": 1, "/api/login": 1, "/api/invalid": 1, "/api/users/789": 1, "/api/profile": 1,
+					"/api/timeout": 1, "/api/upload": 1, "/api/data": 1,
+				},
+			},
+			wantDumped: "2024-01-15 10:30:02,192.168.1.100,GET,/api/users/123,404,50\n" +
+				"2024-01-15 10:30:03,192.168.1.102,GET,/api/products,500,1500\n" +
+				"2024-01-15 10:30:07,192.168.1.105,GET,/api/reports,502,2000\n" +
+				"2024-01-15 10:30:08,192.168.1.100,POST,/api/login,400,80\n" +
+				"2024-01-15 10:30:09,192.168.1.106,GET,/api/invalid,404,40\n" +
+				"2024-01-15 10:30:10,192.168.1.107,DELETE,/api/users/789,403,25\n" +
+				"2024-01-15 10:30:12,192.168.1.108,GET,/api/timeout,504,5000\n" +
+				"2024-01-15 10:30:13,192.168.1.102,POST,/api/upload,413,300\n",
+		},
+		{
+			name:       "--url-pattern=^/api/users",
+			urlPattern: "^/api/users",
+			want: Statistics{
+				TotalRequests:   4,
+				ErrorCount:      2,
+				AverageRespTime: 106.25,
+				RequestsByIP:    map[string]int{"192.168.1.100": 2, "192.168.1.101": 1, "192.168.1.107": 1},
+				RequestsByURL:   map[string]int{"/api/users": 2, "/api/users/123": 1, "/api/users/789": 1},
+			},
+			wantDumped: "2024-01-15 10:30:02,192.168.1.100,GET,/api/users/123,404,50\n" +
+				"2024-01-15 10:30:10,192.168.1.107,DELETE,/api/users/789,403,25\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dump bytes.Buffer
+			opts := defaultOptions()
+			opts.Workers = 1
+			opts.Dump = &dump
+			if tt.urlPattern != "" {
+				opts.URLPattern = regexp.MustCompile(tt.urlPattern)
+			}
+			stats, err := runPipeline(t.Context(), openTestLogs(t), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stats.TotalRequests != tt.want.TotalRequests || stats.ErrorCount != tt.want.ErrorCount {
+				t.Errorf("TotalRequests, ErrorCount = %d, %d, ожидалось %d, %d",
+					stats.TotalRequests, stats.ErrorCount, tt.want.TotalRequests, tt.want.ErrorCount)
+			}
+			if math.Abs(stats.AverageRespTime-tt.want.AverageRespTime) > 1e-9 {
+				t.Errorf("AverageRespTime = %g, ожидалось %g", stats.AverageRespTime, tt.want.AverageRespTime)
+			}
+			if !maps.Equal(stats.RequestsByIP, tt.want.RequestsByIP) {
+				t.Errorf("RequestsByIP = %v, ожидалось %v", stats.RequestsByIP, tt.want.RequestsByIP)
+			}
+			if !maps.Equal(stats.RequestsByURL, tt.want.RequestsByURL) {
+				t.Errorf("RequestsByURL = %v, ожидалось %v", stats.RequestsByURL, tt.want.RequestsByURL)
+			}
+			if dump.String() != tt.wantDumped {
+				t.Errorf("выгружено:\n%s\nожидалось:\n%s", dump.String(), tt.wantDumped)
+			}
+		})
+	}
+}
+
+// Заголовок CSV в исходном порядке колонок
+const testLogsHeader = "timestamp,ip,method,url,status,response_time\n"
+
+// Сгенерированные логи: n записей с повторяющимися IP, URL, статусами и временем
+func generateTestLogs(n int) string {
+	var b strings.Builder
+	b.WriteString(testLogsHeader)
+	methods := []string{"GET", "POST", "get", "DELETE"}
+	statuses := []int{200, 200, 201, 301, 404, 500, 503}
+	for i := range n {
+		fmt.Fprintf(&b, "2024-01-15 %02d:%02d:%02d,10.0.%d.%d,%s,/api/item/%d,%d,%d\n",
+			i/3600%24, i/60%60, i%60, i%7, i%13, methods[i%len(methods)], i%17, statuses[i%len(statuses)], i%997)
+	}
+	return b.String()
+}
+
+// Один и тот же отчет и одни и те же выгруженные записи (без учета порядка) при любом
+// количестве воркеров, размере буфера tee и количестве накопителей статистики.
+// Гонки между стадиями лучше всего ловит запуск с детектором: go test -race ./...
+func TestPipelineDeterministic(t *testing.T) {
+	logs := generateTestLogs(5000)
+
+	run := func(workers, teeBuffer, shards int) (report string, dumped []string) {
+		var dump bytes.Buffer
+		opts := defaultOptions()
+		opts.Workers = workers
+		opts.TeeBufferSize = teeBuffer
+		opts.StatsShards = shards
+		opts.Dump = &dump
+		stats, err := runPipeline(t.Context(), strings.NewReader(logs), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		writeCompactReport(&out, stats, opts)
+		dumped = strings.Split(strings.TrimSpace(dump.String()), "\n")
+		slices.Sort(dumped)
+		return out.String(), dumped
+	}
+
+	wantReport, wantDumped := run(1, 100, 1)
+	if !strings.Contains(wantReport, "total_requests: 5000") {
+		t.Fatalf("неожиданный отчет:\n%s", wantReport)
+	}
+	for _, workers := range []int{2, 4, 8} {
+		for _, teeBuffer := range []int{0, 1, 100} {
+			for _, shards := range []int{1, 3} {
+				t.Run(fmt.Sprintf("workers=%d/tee=%d/shards=%d", workers, teeBuffer, shards), func(t *testing.T) {
+					report, dumped := run(workers, teeBuffer, shards)
+					if report != wantReport {
+						t.Errorf("отчет отличается:\n%s\nожидалось:\n%s", report, wantReport)
+					}
+					if !slices.Equal(dumped, wantDumped) {
+						t.Errorf("выгружено %d записей, ожидалось %d (или записи отличаются)", len(dumped), len(wantDumped))
+					}
+				})
+			}
+		}
+	}
+}
+
+// Бесконечный источник корректных строк лога
+type endlessLogs struct {
+	header bool
+	line   []byte
+	offset int
+}
+
+func (r *endlessLogs) Read(p []byte) (int, error) {
+	if !r.header {
+		r.header = true
+		return copy(p, testLogsHeader), nil
+	}
+	n := 0
+	for n < len(p) {
+		copied := copy(p[n:], r.line[r.offset:])
+		n += copied
+		r.offset = (r.offset + copied) % len(r.line)
+	}
+	return n, nil
+}
+
+// Источник, который после заголовка не отдает данных до отмены контекста
+type stalledLogs struct {
+	ctx    context.Context
+	header bool
+}
+
+func (r *stalledLogs) Read(p []byte) (int, error) {
+	if !r.header {
+		r.header = true
+		return copy(p, testLogsHeader), nil
+	}
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+// Приемник выгрузки, который отстает от pipeline: каждая запись занимает время
+type slowWriter struct{}
+
+func (slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(5 * time.Millisecond)
+	return len(p), nil
+}
+
+// После отмены контекста в любой момент pipeline завершается и не оставляет горутин
+// при любых настройках воркеров, буферов и порядка записей
+func TestPipelineCancelNoLeak(t *testing.T) {
+	const line = "2024-01-15 10:30:00,10.0.0.1,GET,/a,500,10\n"
+	cancelPoints := []struct {
+		name   string
+		source func(ctx context.Context) io.Reader
+		dump   io.Writer
+		delay  time.Duration // через сколько отменять (0 — до запуска)
+	}{
+		{"до запуска", func(context.Context) io.Reader { return &endlessLogs{line: []byte(line)} }, io.Discard, 0},
+		{"во время обработки", func(context.Context) io.Reader { return &endlessLogs{line: []byte(line)} }, io.Discard, 50 * time.Millisecond},
+		{"при ожидании данных", func(ctx context.Context) io.Reader { return &stalledLogs{ctx: ctx} }, io.Discard, 50 * time.Millisecond},
+		{"при отстающей выгрузке", func(context.Context) io.Reader { return &endlessLogs{line: []byte(line)} }, slowWriter{}, 50 * time.Millisecond},
+	}
+	settings := []struct {
+		name          string
+		workers       int
+		teeBuffer     int
+		shards        int
+		preserveOrder bool
+	}{
+		{"workers=1/tee=0", 1, 0, 1, false},
+		{"workers=4/tee=100/shards=3", 4, 100, 3, false},
+		{"workers=8/tee=1/preserve-order", 8, 1, 2, true},
+	}
+
+	for _, cp := range cancelPoints {
+		for _, st := range settings {
+			t.Run(cp.name+"/"+st.name, func(t *testing.T) {
+				defer goleak.VerifyNone(t)
+
+				ctx, cancel := context.WithCancel(t.Context())
+				defer cancel()
+				if cp.delay == 0 {
+					cancel()
+				} else {
+					time.AfterFunc(cp.delay, cancel)
+				}
+				opts := defaultOptions()
+				opts.Workers = st.workers
+				opts.TeeBufferSize = st.teeBuffer
+				opts.StatsShards = st.shards
+				opts.PreserveOrder = st.preserveOrder
+				opts.Dump = cp.dump
+				_, err := runPipeline(ctx, cp.source(ctx), opts)
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("ошибка %v, ожидалась context.Canceled", err)
+				}
+			})
+		}
+	}
+}
+func TestParseErrorCodes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[int]bool
+		wantErr bool
+	}{
+		{value: "500", want: map[int]bool{500: true}},
+		{value: "500, 502,503,504", want: map[int]bool{500: true, 502: true, 503: true, 504: true}},
+		{value: "500,500", want: map[int]bool{500: true}},
+		{value: "", wantErr: true},
+		{value: "500,", wantErr: true},
+		{value: "5xx", wantErr: true},
+		{value: "99", wantErr: true},
+		{value: "600", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseErrorCodes(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseErrorCodes(%q): ошибка %v, ожидалась ошибка: %t", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !maps.Equal(got, tt.want) {
+			t.Errorf("parseErrorCodes(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// С --error-codes ошибкой считается только статус из набора, порог --error-status
+// при этом не действует
+func TestErrorCodes(t *testing.T) {
+	opts := defaultOptions()
+	opts.ErrorStatus = 400
+	opts.ErrorCodes = map[int]bool{500: true, 502: true, 503: true, 504: true}
+
+	isError := errorFilter(opts)
+	for status, want := range map[int]bool{200: false, 404: false, 500: true, 501: false, 502: true, 504: true, 505: false} {
+		if got := isError(LogEntry{StatusCode: status}); got != want {
+			t.Errorf("статус %d считается ошибкой: %t, ожидалось %t", status, got, want)
+		}
+	}
+	if got := errorDefinition(opts); got != "статус 500, 502, 503, 504" {
+		t.Errorf("errorDefinition = %q", got)
+	}
+
+	entries := []LogEntry{
+		{Timestamp: "2024-01-15 10:30:00", StatusCode: 404},
+		{Timestamp: "2024-01-15 10:30:01", StatusCode: 500},
+		{Timestamp: "2024-01-15 10:30:02", StatusCode: 501},
+		{Timestamp: "2024-01-15 10:30:03", StatusCode: 503},
+	}
+	stats := calculateTestStats(entries, opts)
+	if stats.ErrorCount != 2 {
+		t.Errorf("ErrorCount = %d, ожидалось 2", stats.ErrorCount)
+	}
+	if got := stats.FirstErrorAt.Format(timestampLayout); got != "2024-01-15 10:30:01" {
+		t.Errorf("FirstErrorAt = %s, ожидалось 2024-01-15 10:30:01", got)
+	}
+	if got := stats.LastErrorAt.Format(timestampLayout); got != "2024-01-15 10:30:03" {
+		t.Errorf("LastErrorAt = %s, ожидалось 2024-01-15 10:30:03", got)
+	}
+
+	// Без набора действует порог
+	opts.ErrorCodes = nil
+	if stats := calculateTestStats(entries, opts); stats.ErrorCount != 4 {
+		t.Errorf("без --error-codes ErrorCount = %d, ожидалось 4", stats.ErrorCount)
+	}
+}
+
+// Каждый из потребителей с разной скоростью получает все записи в исходном порядке,
+// после чего его канал закрывается
+func TestFanOut(t *testing.T) {
+	entries := generateTestEntries(200)
+	outs := fanOut(entriesChan(entries, 0), 3, 4)
+	if len(outs) != 3 {
+		t.Fatalf("каналов %d, ожидалось 3", len(outs))
+	}
+
+	delays := []time.Duration{0, 10 * time.Microsecond, 100 * time.Microsecond}
+	results := make([][]LogEntry, len(outs))
+	done := make(chan int)
+	for i, out := range outs {
+		go func() {
+			for logEntry := range out {
+				results[i] = append(results[i], logEntry)
+				time.Sleep(delays[i])
+			}
+			done <- i
+		}()
+	}
+	for range outs {
+		<-done
+	}
+	for i, got := range results {
+		if !slices.Equal(got, entries) {
+			t.Errorf("потребитель %d получил %d записей, ожидалось %d (или порядок отличается)", i, len(got), len(entries))
+		}
+	}
+}
+
+// Остановившийся потребитель не задерживает остальных, пока у него есть место
+// в буфере, а после этого сдерживает весь поток
+func TestFanOutBackpressure(t *testing.T) {
+	const bufferSize = 2
+	entries := generateTestEntries(10)
+	outs := fanOut(entriesChan(entries, 0), 2, bufferSize)
+	fast, stalled := outs[0], outs[1]
+
+	// Буфер остановившегося заполняется bufferSize записями, следующую fanOut
+	// держит у себя, и ее уже получает быстрый потребитель
+	for i := range bufferSize + 1 {
+		select {
+		case logEntry := <-fast:
+			if logEntry != entries[i] {
+				t.Fatalf("запись %d: %+v, ожидалось %+v", i, logEntry, entries[i])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("быстрый потребитель не получил запись %d", i)
+		}
+	}
+	select {
+	case logEntry := <-fast:
+		t.Fatalf("быстрый потребитель получил %+v, пока остановившийся не освободил буфер", logEntry)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Остановившийся потребитель продолжает чтение: оба получают все записи
+	restChan := make(chan []LogEntry)
+	go func() { restChan <- collectEntries(fast) }()
+	if got := collectEntries(stalled); !slices.Equal(got, entries) {
+		t.Errorf("остановившийся потребитель получил %d записей, ожидалось %d", len(got), len(entries))
+	}
+	if rest := <-restChan; !slices.Equal(rest, entries[bufferSize+1:]) {
+		t.Errorf("быстрый потребитель получил после разблокировки %d записей, ожидалось %d", len(rest), len(entries)-bufferSize-1)
+	}
+}
+
+// Записи со временем ответа вне --min-response-time..--max-response-time
+// отбрасываются до подсчета статистики и считаются отдельно
+func TestResponseTimeBounds(t *testing.T) {
+	const logs = testLogsHeader +
+		"2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n" +
+		"2024-01-15 10:30:01,10.0.0.1,GET,/a,200,-5\n" +
+		"2024-01-15 10:30:02,10.0.0.1,GET,/a,200,30\n" +
+		"2024-01-15 10:30:03,10.0.0.1,GET,/a,200,4000000000\n" +
+		"2024-01-15 10:30:04,10.0.0.1,GET,/a,200,0\n" +
+		"2024-01-15 10:30:05,10.0.0.1,GET,/a,200,600000\n"
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantTotal    int
+		wantOutliers int
+		wantAvg      float64
+		wantRange    string
+	}{
+		{"без границ", nil, 6, 0, (10 - 5 + 30 + 4000000000 + 600000) / 6.0, ""},
+		{"нижняя граница", []string{"--min-response-time=0ms"}, 5, 1, (10 + 30 + 4000000000 + 600000) / 5.0, ">=0ms"},
+		{"верхняя граница", []string{"--max-response-time=10m"}, 5, 1, (10 - 5 + 30 + 600000) / 5.0, "<=600000ms"},
+		{"обе границы", []string{"--min-response-time=1ms", "--max-response-time=1s"}, 2, 4, 20, "1ms..1000ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAnalyzeConfig()
+			if err := cfg.flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			opts := cfg.opts
+			if got := hasResponseTimeRange(opts); got != (tt.wantRange != "") {
+				t.Fatalf("hasResponseTimeRange = %t", got)
+			}
+			if tt.wantRange != "" {
+				if got := describeResponseTimeRange(opts); got != tt.wantRange {
+					t.Errorf("describeResponseTimeRange = %q, ожидалось %q", got, tt.wantRange)
+				}
+			}
+
+			stats, err := runPipeline(t.Context(), strings.NewReader(logs), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.TotalRequests != tt.wantTotal || stats.ResponseTimeOutliers != tt.wantOutliers {
+				t.Errorf("TotalRequests, ResponseTimeOutliers = %d, %d, ожидалось %d, %d",
+					stats.TotalRequests, stats.ResponseTimeOutliers, tt.wantTotal, tt.wantOutliers)
+			}
+			if math.Abs(stats.AverageRespTime-tt.wantAvg) > 1e-6 {
+				t.Errorf("AverageRespTime = %g, ожидалось %g", stats.AverageRespTime, tt.wantAvg)
+			}
+		})
+	}
+}
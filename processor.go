@@ -3,98 +3,565 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"math"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Структура для одной записи лога
 type LogEntry struct {
-	Timestamp    string // время в формате "2024-01-15 10:30:00"
-	IP           string // IP адрес клиента
-	Method       string // HTTP метод (GET, POST и т.д.)
-	URL          string // путь запроса
-	StatusCode   int    // HTTP статус код
-	ResponseTime int    // время ответа в миллисекундах
+	Timestamp    string  // время в формате "2024-01-15 10:30:00"
+	IP           string  // IP адрес клиента
+	Method       string  // HTTP метод (GET, POST и т.д.)
+	URL          string  // путь запроса
+	StatusCode   int     // HTTP статус код
+	ResponseTime float64 // время ответа в миллисекундах, возможно дробное (всегда приводится к ms при парсинге)
+	Bytes        int     // размер ответа в байтах (0, если колонки нет в схеме)
+	UserAgent    string  // User-Agent клиента (пусто, если колонки нет в схеме)
+	Referer      string  // Referer запроса (пусто, если колонки нет в схеме)
+	CacheStatus  string  // статус кэша CDN: HIT, MISS, BYPASS и т.д. (пусто, если колонки нет в схеме)
+
+	seq int // порядковый номер записи во входных данных (только с --preserve-order)
+}
+
+// Количество запросов по классам статусов: индекс — первая цифра кода (1xx..5xx),
+// в нулевой ячейке — коды вне диапазона 100–599
+type statusClassCounts [6]int
+
+// Класс статуса (первая цифра кода) или 0 для кодов вне диапазона 100–599
+func statusClass(statusCode int) int {
+	class := statusCode / 100
+	if class < 1 || class > 5 {
+		return 0
+	}
+	return class
+}
+
+// Ключ RequestsByStatus: код статуса или, по классам, первый код класса (204 → 200)
+func statusKey(statusCode int, byClass bool) int {
+	if byClass {
+		return statusClass(statusCode) * 100
+	}
+	return statusCode
+}
+
+// Подпись ключа RequestsByStatus: "204" или, по классам, "2xx" ("other" вне диапазона)
+func statusLabel(key int, byClass bool) string {
+	switch {
+	case !byClass:
+		return strconv.Itoa(key)
+	case key == 0:
+		return "other"
+	default:
+		return fmt.Sprintf("%dxx", key/100)
+	}
+}
+
+// Строка вида "2xx:4800 4xx:180 5xx:20" (только ненулевые классы)
+func (c *statusClassCounts) String() string {
+	var parts []string
+	for class := 1; class < len(c); class++ {
+		if c[class] > 0 {
+			parts = append(parts, fmt.Sprintf("%dxx:%s", class, formatCount(c[class])))
+		}
+	}
+	if c[0] > 0 {
+		parts = append(parts, fmt.Sprintf("other:%s", formatCount(c[0])))
+	}
+	return strings.Join(parts, " ")
 }
 
+// Формат времени в колонке timestamp
+const timestampLayout = "2006-01-02 15:04:05"
+
 // Структура для сбора статистики
 type Statistics struct {
-	TotalRequests   int            // общее количество запросов
-	ErrorCount      int            // количество ошибок (статус >= 400)
-	RequestsByIP    map[string]int // количество запросов с каждого IP
-	AverageRespTime float64        // среднее время ответа
+	TotalRequests      int                           // общее количество запросов
+	ErrorCount         int                           // количество ошибок (Options.ErrorCodes или статус >= Options.ErrorStatus)
+	FirstErrorAt       time.Time                     // время самой ранней ошибки (нулевое, если ошибок нет или время не распознано)
+	LastErrorAt        time.Time                     // время самой поздней ошибки
+	SuccessCount       int                           // количество успешных ответов (2xx)
+	RedirectCount      int                           // количество перенаправлений (3xx)
+	RequestsByStatus   map[int]int                   // количество ответов по кодам статуса (--openmetrics-out)
+	RequestsByIP       map[string]int                // количество запросов с каждого IP
+	IPGini             float64                       // коэффициент Джини распределения запросов по IP
+	RequestsByMethod   map[string]int                // количество запросов по HTTP методам
+	RequestsByURL      map[string]int                // количество запросов по URL (или по префиксу пути)
+	RequestsByEndpoint map[string]int                // количество запросов по эндпоинтам ("GET /api/users")
+	RequestsByParam    map[string]int                // количество запросов по значениям параметра query string (--group-by-param)
+	URLStatusClasses   map[string]*statusClassCounts // разбивка запросов к каждому URL по классам статусов (--verbose)
+	ErrorsByURL        map[string]int                // количество ошибок по URL (--top-by=url-errors)
+	BytesByIP          map[string]int                // сумма размеров ответов по IP (--top-by=ip-bytes)
+	RespTimeByURL      map[string]float64            // сумма времени ответа по URL в ms (--top-by=url-latency)
+	RequestsByReferer  map[string]int                // количество запросов по Referer (--top-by=referer)
+	RequestsByUA       map[string]int                // количество запросов по User-Agent (--top-by=user-agent)
+	CacheStatusCounts  map[string]int                // количество ответов по статусу кэша CDN (пусто, если колонки cache_status нет)
+	WorkerCounts       []int                         // количество записей, обработанных каждым воркером (если включено)
+	URLDecodeErrors    int                           // количество URL, которые не удалось раскодировать (--decode-urls)
+	SkippedLines       skippedLines                  // количество нераспознанных строк по причинам
+	Sample             logSample                     // первые и последние разобранные записи (--head, --tail)
+	AverageRespTime    float64                       // среднее время ответа
+	StdDevRespTime     float64                       // стандартное отклонение времени ответа
+	AnomalyThreshold   float64                       // порог аномальной задержки: среднее + k·σ (--anomaly-sigma)
+	AnomalyCount       int                           // количество записей со временем ответа выше порога
+	SlowCount          int                           // количество запросов дольше --slow-threshold
+	FastCount          int                           // количество запросов быстрее --fast-threshold
+	Anomalies          []LogEntry                    // самые медленные из этих записей (по убыванию времени ответа)
+	RequestsByClass    statusClassCounts             // количество запросов по классам статусов
+	AvgRespTimeByClass [6]float64                    // среднее время ответа по классам статусов (индексы как у RequestsByClass)
+	FirstTimestamp     time.Time                     // самое раннее время записи (нулевое, если время не распознано)
+	LastTimestamp      time.Time                     // самое позднее время записи
+	PeakRate           int                           // наибольшее количество запросов за одну секунду
+	PeakSecond         time.Time                     // секунда, на которую пришелся пик
+	RequestsByHour     [24]int                       // количество запросов по часам суток (все дни вместе)
+	CoverageGaps       []timeGap                     // периоды без запросов длиной от --gap-interval (по возрастанию времени)
+	Spikes             []trafficSpike                // всплески нагрузки по --spike-factor (по возрастанию времени)
+	ErrorCorrelations  []errorCorrelation            // ответы 5xx с сообщениями из журнала ошибок (--error-log)
+
+	// Количество запросов по дням недели и часам суток (--heatmap-out): строки —
+	// дни с понедельника по воскресенье, колонки — часы
+	RequestsByWeekdayHour [7][24]int
+
+	// Записи, отброшенные из-за времени ответа вне допустимого диапазона
+	// (--min-response-time, --max-response-time)
+	ResponseTimeOutliers int
+
+	// Соответствие целям SLO (--slo-config), в порядке целей в файле
+	SLOResults []sloResult
+}
+
+// Набор показателей, которые вычисляет calculateStats (битовая маска)
+type statsSelection uint
+
+const (
+	statTotal         statsSelection = 1 << iota // общее количество запросов
+	statErrors                                   // количество ошибок
+	statAvgTime                                  // среднее время ответа
+	statTopIPs                                   // запросы по IP
+	statTopURLs                                  // запросы по URL
+	statMethods                                  // запросы по HTTP методам
+	statTopEndpoints                             // запросы по эндпоинтам (метод + URL)
+	statTimeSpan                                 // период, который охватывают логи
+	statPeakRate                                 // пиковое количество запросов за одну секунду
+	statStatusClasses                            // успешные (2xx) и перенаправления (3xx)
+	statHourOfDay                                // распределение запросов по часам суток
+	statCacheStatus                              // ответы по статусу кэша CDN (если есть колонка cache_status)
+
+	// Все показатели: маска из всех битов, объявленных выше
+	statAll statsSelection = 1<<iota - 1
+
+	// Показатели по умолчанию (без --stats): все, кроме гистограммы по часам суток —
+	// 24 строки она добавляет только по --stats=hours или --verbose
+	statDefault = statAll &^ statHourOfDay
+
+	// Показатели, для которых нужно разбирать время записи
+	statNeedsTimestamp = statTimeSpan | statPeakRate | statHourOfDay
+
+	// Рейтинги и распределения (таблицы отчета), которые отключает --summary-only
+	statRankings = statTopIPs | statTopURLs | statMethods | statTopEndpoints | statHourOfDay | statCacheStatus
+)
+
+// Имена показателей для флага --stats
+var statsSelectionNames = map[string]statsSelection{
+	"total":     statTotal,
+	"errors":    statErrors,
+	"avg":       statAvgTime,
+	"topips":    statTopIPs,
+	"topurls":   statTopURLs,
+	"methods":   statMethods,
+	"endpoints": statTopEndpoints,
+	"span":      statTimeSpan,
+	"peak":      statPeakRate,
+	"classes":   statStatusClasses,
+	"hours":     statHourOfDay,
+	"cache":     statCacheStatus,
+}
+
+// Проверяем, включен ли показатель
+func (s statsSelection) has(stat statsSelection) bool {
+	return s&stat != 0
+}
+
+// Список включенных показателей через запятую ("all", если включены все,
+// "default" для показателей по умолчанию)
+func (s statsSelection) String() string {
+	switch s {
+	case statAll:
+		return "all"
+	case statDefault:
+		return "default"
+	}
+	var names []string
+	for name, stat := range statsSelectionNames {
+		if s.has(stat) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// Разбираем список показателей через запятую, например "total,errors,topips"
+func parseStatsSelection(value string) (statsSelection, error) {
+	var selection statsSelection
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		stat, ok := statsSelectionNames[name]
+		if !ok {
+			return 0, fmt.Errorf("неизвестный показатель статистики %q", name)
+		}
+		selection |= stat
+	}
+	return selection, nil
+}
+
+// Причина, по которой строка лога не разобрана
+type parseErrorKind int
+
+const (
+	parseErrFieldCount   parseErrorKind = iota // количество полей не совпадает со схемой
+	parseErrStatus                             // код ответа не число
+	parseErrResponseTime                       // время ответа не число
+	parseErrTimestamp                          // время не соответствует формату схемы
+	parseErrBytes                              // размер ответа не число
+	numParseErrorKinds
+)
+
+// Имена причин в отчете о пропущенных строках
+var parseErrorKindNames = [numParseErrorKinds]string{
+	parseErrFieldCount:   "field-count",
+	parseErrStatus:       "bad-status",
+	parseErrResponseTime: "bad-response-time",
+	parseErrTimestamp:    "bad-time",
+	parseErrBytes:        "bad-bytes",
+}
+
+func (k parseErrorKind) String() string {
+	return parseErrorKindNames[k]
+}
+
+// Описание причины в сообщении об ошибке
+var parseErrorMessages = [numParseErrorKinds]string{
+	parseErrFieldCount:   "неверный формат логов",
+	parseErrStatus:       "неверный код ответа",
+	parseErrResponseTime: "неверное время ответа",
+	parseErrTimestamp:    "неверное время",
+	parseErrBytes:        "неверный размер ответа",
+}
+
+// Ошибка разбора строки лога. По ней вызывающий может узнать, какая колонка
+// не разобрана, не разбирая текст сообщения.
+type ParseError struct {
+	Line   int    // номер строки во входных данных, начиная с 1
+	Field  string // имя колонки с неверным значением (пусто — не совпало количество полей)
+	Reason string // подробности: ошибка преобразования значения или количество полей
+	Raw    string // исходная строка
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s в строке %d: %s", parseErrorMessages[e.kind()], e.Line, e.Reason)
+}
+
+// Причина ошибки по имени колонки
+func (e *ParseError) kind() parseErrorKind {
+	switch e.Field {
+	case fieldName(fieldStatus):
+		return parseErrStatus
+	case fieldName(fieldResponseTime):
+		return parseErrResponseTime
+	case fieldName(fieldTimestamp):
+		return parseErrTimestamp
+	case fieldName(fieldBytes):
+		return parseErrBytes
+	default:
+		return parseErrFieldCount
+	}
+}
+
+// Ошибка разбора значения колонки field в строке lineNumber (нумерация с нуля)
+func fieldParseError(lineNumber int, field logField, err error, line string) error {
+	return &ParseError{Line: lineNumber + 1, Field: fieldName(field), Reason: err.Error(), Raw: line}
+}
+
+// Количество пропущенных (нераспознанных) строк по причинам
+type skippedLines struct {
+	Total  int
+	ByKind [numParseErrorKinds]int
 }
 
-// Парсим строку CSV в структуру LogEntry
-func parseLogLine(line string, lineNumber int) (LogEntry, error) {
-	fields := strings.Split(line, ",")
-	// если кол-во полей не равно 6, передаем ошибку
-	if len(fields) != 6 {
-		return LogEntry{}, fmt.Errorf("неверный формат логов в строке %d: ", lineNumber+1)
+// Учитываем ошибку разбора строки
+func (s *skippedLines) add(err error) {
+	s.Total++
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		s.ByKind[parseErr.kind()]++
+	}
+}
+
+// Строка вида "120 (bad-status:80, field-count:30, bad-time:10)":
+// причины по убыванию количества, нулевые не выводятся
+func (s skippedLines) String() string {
+	kinds := make([]parseErrorKind, 0, numParseErrorKinds)
+	for kind := range numParseErrorKinds {
+		if s.ByKind[kind] > 0 {
+			kinds = append(kinds, kind)
+		}
+	}
+	slices.SortStableFunc(kinds, func(a, b parseErrorKind) int {
+		return s.ByKind[b] - s.ByKind[a]
+	})
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s:%s", kind, formatCount(s.ByKind[kind]))
+	}
+	if len(parts) == 0 {
+		return formatCount(s.Total)
+	}
+	return fmt.Sprintf("%s (%s)", formatCount(s.Total), strings.Join(parts, ", "))
+}
+
+// Парсим строку CSV в структуру LogEntry согласно схеме.
+// При ошибке возвращается *ParseError.
+func parseLogLine(line string, lineNumber int, schema logSchema) (LogEntry, error) {
+	fields := schema.splitFields(line)
+	// пробелы по краям значений (" GET ") убираем, чтобы они не дробили статистику
+	if !schema.noTrim {
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+	}
+	// лишние колонки в конце строки отбрасываем, если схема их допускает
+	if schema.allowExtra && len(fields) > schema.columns {
+		fields = fields[:schema.columns]
+	}
+	// если кол-во полей не совпадает со схемой, передаем ошибку
+	if len(fields) != schema.columns {
+		got := len(fields)
+		if schema.widths == nil {
+			got = strings.Count(line, schema.delimiter) + 1
+		}
+		return LogEntry{}, &ParseError{
+			Line:   lineNumber + 1,
+			Reason: fmt.Sprintf("ожидалось полей %d, получено %d", schema.columns, got),
+			Raw:    line,
+		}
 	}
 
 	// проверка корректности содержимого поля statusCode
-	statusCode, err := strconv.Atoi(fields[4])
+	statusCode, err := strconv.Atoi(fields[schema.index[fieldStatus]])
 	if err != nil {
-		return LogEntry{}, fmt.Errorf("неверный код ответа в строке %d: %v", lineNumber+1, err)
+		return LogEntry{}, fieldParseError(lineNumber, fieldStatus, err, line)
 	}
 
 	// проверка корректности содержимого поля responseTime
-	responseTime, err := strconv.Atoi(fields[5])
+	// (допускаются дробные миллисекунды, например 0.427; NaN и бесконечность — нет)
+	responseTime, err := strconv.ParseFloat(fields[schema.index[fieldResponseTime]], 64)
+	if err == nil && (math.IsNaN(responseTime) || math.IsInf(responseTime, 0)) {
+		err = errors.New("время ответа должно быть конечным числом")
+	}
 	if err != nil {
-		return LogEntry{}, fmt.Errorf("неверное время ответа в строке %d: %v", lineNumber+1, err)
+		return LogEntry{}, fieldParseError(lineNumber, fieldResponseTime, err, line)
 	}
 
-	return LogEntry{
-		Timestamp:    fields[0],
-		IP:           fields[1],
-		Method:       fields[2],
-		URL:          fields[3],
+	logEntry := LogEntry{
+		Timestamp:    fields[schema.index[fieldTimestamp]],
+		IP:           fields[schema.index[fieldIP]],
+		Method:       fields[schema.index[fieldMethod]],
+		URL:          fields[schema.index[fieldURL]],
 		StatusCode:   statusCode,
-		ResponseTime: responseTime,
-	}, nil
+		ResponseTime: schema.timeUnit.toMillis(responseTime),
+	}
+
+	// время в нестандартном формате приводим к timestampLayout
+	if schema.timestampFormat != timestampLayout {
+		ts, err := time.Parse(schema.timestampFormat, logEntry.Timestamp)
+		if err != nil {
+			return LogEntry{}, fieldParseError(lineNumber, fieldTimestamp, err, line)
+		}
+		logEntry.Timestamp = ts.Format(timestampLayout)
+	}
+
+	// необязательные поля заполняем, только если они есть в схеме
+	if schema.has(fieldBytes) {
+		logEntry.Bytes, err = strconv.Atoi(fields[schema.index[fieldBytes]])
+		if err != nil {
+			return LogEntry{}, fieldParseError(lineNumber, fieldBytes, err, line)
+		}
+	}
+	if schema.has(fieldUserAgent) {
+		logEntry.UserAgent = fields[schema.index[fieldUserAgent]]
+	}
+	if schema.has(fieldReferer) {
+		logEntry.Referer = fields[schema.index[fieldReferer]]
+	}
+	if schema.has(fieldCacheStatus) {
+		logEntry.CacheStatus = strings.ToUpper(fields[schema.index[fieldCacheStatus]])
+	}
+
+	return logEntry, nil
+}
+
+// Ошибка readLogs для пустого входа (нет даже строки заголовка)
+var ErrEmptyInput = errors.New("входные данные пусты")
+
+// Маркер порядка байтов UTF-8, который добавляют некоторые Windows-программы
+const utf8BOM = "\ufeff"
+
+// Очищаем строку от артефактов Windows: BOM в начале и \r в конце строки
+func cleanLine(line string) string {
+	line = strings.TrimPrefix(line, utf8BOM)
+	return strings.TrimRight(line, "\r")
 }
 
-// Функция readLogs читает файл с логами, построчно парсит строки и отправляет
+// Функция readLogs читает логи из r, построчно парсит строки и отправляет
 // полученные записи (LogEntry) в канал для дальнейшей обработки.
 // Функция запускает внутреннюю горутину, которая закрывает канал после завершения.
-func readLogs(ctx context.Context, filename string) (<-chan LogEntry, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// Если opts.Rejects не nil, в него записываются нераспознанные строки вместе с номером
+// строки и причиной ошибки (через табуляцию). Пропущенные строки считаются по причинам
+// в skipped; читать счетчики можно после закрытия выходного канала.
+func readLogs(ctx context.Context, r io.Reader, opts Options, skipped *skippedLines) (<-chan LogEntry, error) {
+	// С --read-rate сканер получает данные не быстрее заданной скорости
+	if opts.ReadRate > 0 {
+		r = newRateLimitedReader(ctx, r, opts.ReadRate)
 	}
 
-	// проверяем открылся ли файл
-	info, err := file.Stat()
-	if err != nil {
-		fmt.Println("Ошибка получения информации о файле:", err)
+	// Создаем сканер для построчного чтения файла
+	scanner := bufio.NewScanner(r)
+
+	// Считываем первую строку синхронно, чтобы сразу сообщить
+	// вызывающему об ошибке чтения или пустом входе
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrEmptyInput
+	}
+	firstLine := cleanLine(scanner.Text())
+
+	// Схема либо задана явно (--schema), либо определяется по заголовку CSV
+	var schema logSchema
+	var header string
+	if opts.Schema != nil {
+		schema = *opts.Schema
+		if schema.hasHeader {
+			header = firstLine
+		}
+	} else {
+		header = firstLine
+		var err error
+		schema, err = detectSchema(header)
+		if err != nil {
+			// С --auto-header нераспознанный заголовок — обычно строка данных
+			if !opts.AutoHeader {
+				log.Printf("%v; используется схема по умолчанию", err)
+			}
+			schema = defaultSchema()
+		}
+		schema.timeUnit = opts.TimeUnit
+	}
+	schema.allowExtra = opts.AllowExtraFields
+	schema.noTrim = opts.NoTrim
+
+	// С --auto-header заголовок определяется по содержимому: если первая
+	// строка разбирается как запись, заголовка нет и это уже данные
+	if opts.AutoHeader && header != "" {
+		if _, err := parseLogLine(firstLine, 0, schema); err == nil {
+			header = ""
+		}
+	}
+
+	// Показываем, как колонки сопоставлены полям (--print-schema)
+	if opts.PrintSchema {
+		fmt.Fprint(os.Stderr, schema.describe(header))
 	}
-	fmt.Println("Имя файла:", info.Name())
 
 	// Создаем выходной канал для передачи обработанных записей лога
 	out := make(chan LogEntry)
 
-	// Запускаем горутину, которая будет читать и парсить файл
-	go func() {
-		defer close(out)   // закрываем канал когда горутина завершится
-		defer file.Close() // закрываем файл когда горутина завершится
+	// Разбор одной строки и отправка записи в канал.
+	// Возвращает false, если контекст отменен и чтение нужно прекратить.
+	handleLine := func(line string, lineNumber int) bool {
+		// Пустые строки и повторные заголовки (при чтении нескольких файлов подряд) пропускаем
+		if line == "" || line == header {
+			return true
+		}
 
-		// Создаем сканер для построчного чтения файла
-		scanner := bufio.NewScanner(file)
+		// Парсим строку, передавая её номер для более информативной ошибки
+		logEntry, err := parseLogLine(line, lineNumber, schema)
 
-		// Счетчик номера текущей строки в файле (для диагностики ошибок)
-		lineNumber := 0
+		// С --auto-header заголовки следующих файлов тоже пропускаем без ошибки
+		if err != nil && opts.AutoHeader && isHeaderLine(line) {
+			return true
+		}
 
-		// Считываем первую строку - заголовок CSV - пропускаем ее
-		if !scanner.Scan() {
-			log.Printf("Не удалось считать заголовок или файл пуст")
-			if err := scanner.Err(); err != nil {
-				log.Fatalf("Ошибка сканера: %v", err)
+		// При ошибке парсинга выводим сообщение в лог, строку пропускаем
+		if err != nil {
+			log.Printf("ошибка при парсинге логов строка %d: %v", lineNumber+1, err)
+			skipped.add(err)
+			if opts.Rejects != nil {
+				if _, werr := fmt.Fprintf(opts.Rejects, "%d\t%v\t%s\n", lineNumber+1, err, line); werr != nil {
+					log.Printf("ошибка записи в файл отклоненных строк: %v", werr)
+				}
+			}
+			return true // при ошибке парсинга пропускаем строку
+		}
+
+		// Отправляем успешно разобранную запись в канал для дальнейшей обработки,
+		// не блокируясь навсегда, если контекст отменен
+		select {
+		case out <- logEntry:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// С --multiline строка, которая не начинается как запись (opts.RecordStart),
+	// продолжает предыдущую запись: строки собираются через "\n" и разбираются
+	// вместе, когда начинается следующая запись или заканчиваются данные.
+	// Номер строки записи — номер ее первой строки.
+	feedLine := handleLine
+	var record string
+	recordLine := 0
+	flushRecord := func() bool {
+		line := record
+		record = ""
+		return handleLine(line, recordLine)
+	}
+	if opts.RecordStart != nil {
+		feedLine = func(line string, lineNumber int) bool {
+			if record != "" && line != header && !opts.RecordStart.MatchString(line) {
+				record += "\n" + line
+				return true
 			}
+			ok := flushRecord()
+			record, recordLine = line, lineNumber
+			return ok
+		}
+	}
+
+	// Запускаем горутину, которая будет читать и парсить данные
+	go func() {
+		defer close(out) // закрываем канал когда горутина завершится
+
+		// Счетчик номера текущей строки в файле, начиная с нуля (для диагностики ошибок)
+		lineNumber := 0
+
+		// Если заголовка нет, первая строка — уже данные
+		if header == "" && !feedLine(firstLine, lineNumber) {
 			return
 		}
 
@@ -108,22 +575,19 @@ func readLogs(ctx context.Context, filename string) (<-chan LogEntry, error) {
 				fmt.Printf("Контекст отменен\n")
 				return
 			default:
-				// Получаем текст текущей строки
-				line := scanner.Text()
-
-				// Парсим строку, передавая её номер для более информативной ошибки
-				logEntry, err := parseLogLine(line, lineNumber)
-
-				// При ошибке парсинга выводим сообщение в лог, строку пропускаем
-				if err != nil {
-					log.Printf("ошибка при парсинге логов строка %d: %v", lineNumber+1, err)
-					continue // при ошибке парсинга пропускаем строку
+				if !feedLine(cleanLine(scanner.Text()), lineNumber) {
+					return
 				}
-
-				// Отправляем успешно разобранную запись в канал для дальнейшей обработки
-				out <- logEntry
 			}
 		}
+		// Последняя собранная запись
+		if opts.RecordStart != nil && !flushRecord() {
+			return
+		}
+		// Отмена контекста во время ожидания --read-rate — не ошибка чтения
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("ошибка чтения логов: %v", err)
+		}
 	}()
 
 	// Возвращаем канал, из которого можно читать лог-записи
@@ -131,26 +595,38 @@ func readLogs(ctx context.Context, filename string) (<-chan LogEntry, error) {
 }
 
 // Обработка логов с использованием worker pool
-// параллельно обрабатываем записи из канала input, возвращаем канал с результатами
-func processLogs(ctx context.Context, input <-chan LogEntry, numWorkers int) <-chan LogEntry {
+// параллельно обрабатываем записи из канала input, возвращаем канал с результатами.
+// Если workerCounts не nil (длиной numWorkers), каждый воркер записывает в свою ячейку
+// количество обработанных им записей; читать их можно после закрытия выходного канала.
+// Если preserveOrder, записи нумеруются на входе и выдаются в исходном порядке (см. reorderLogs).
+func processLogs(ctx context.Context, input <-chan LogEntry, numWorkers int, workerCounts []int, preserveOrder bool) <-chan LogEntry {
 	out := make(chan LogEntry)
 	var wg sync.WaitGroup
 
-	worker := func() {
+	if preserveOrder {
+		input = numberLogs(ctx, input)
+	}
+
+	worker := func(id int) {
 		defer wg.Done()
+		count := 0
+		// Каждый воркер пишет только в свою ячейку, поэтому блокировка не нужна
+		if workerCounts != nil {
+			defer func() { workerCounts[id] = count }()
+		}
 		for logEntry := range input {
 			select {
 			case <-ctx.Done():
 				return
-			default:
-				out <- logEntry
+			case out <- logEntry:
+				count++
 			}
 		}
 	}
 
 	wg.Add(numWorkers)
 	for i := 0; i < numWorkers; i++ {
-		go worker()
+		go worker(i)
 	}
 
 	// Закрываем канал после завершения всех воркеров
@@ -159,32 +635,315 @@ func processLogs(ctx context.Context, input <-chan LogEntry, numWorkers int) <-c
 		close(out)
 	}()
 
+	if preserveOrder {
+		return reorderLogs(ctx, out)
+	}
+	return out
+}
+
+// Нумерация записей в порядке поступления (поле seq)
+func numberLogs(ctx context.Context, input <-chan LogEntry) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		seq := 0
+		for logEntry := range input {
+			logEntry.seq = seq
+			seq++
+			select {
+			case <-ctx.Done():
+				return
+			case out <- logEntry:
+			}
+		}
+	}()
+
+	return out
+}
+
+// Восстановление исходного порядка пронумерованных записей: запись, пришедшая раньше
+// своей очереди, ждет в буфере, пока не будут выданы все предыдущие. Буфер растет
+// на столько записей, на сколько воркеры обогнали самую медленную из них.
+func reorderLogs(ctx context.Context, input <-chan LogEntry) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		pending := make(map[int]LogEntry)
+		next := 0
+		for logEntry := range input {
+			pending[logEntry.seq] = logEntry
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				select {
+				case <-ctx.Done():
+					return
+				case out <- ready:
+				}
+			}
+		}
+	}()
+
 	return out
 }
 
 // Функция разветвления каналов для filtered и unfiltered данных с использованием буферизованных каналов
 func tee(in <-chan LogEntry, bufferSize int) (<-chan LogEntry, <-chan LogEntry) {
-	out1 := make(chan LogEntry, bufferSize)
-	out2 := make(chan LogEntry, bufferSize)
+	outs := fanOut(in, 2, bufferSize)
+	return outs[0], outs[1]
+}
+
+// Рассылка каждой записи из in в n каналов, у каждого свой буфер размером bufferSize.
+// Запись отдается потребителям в том порядке, в каком освобождаются их буферы, поэтому
+// заполненный буфер одного потребителя не задерживает доставку остальным. Следующая
+// запись читается из in, только когда текущую получили все: самый медленный потребитель
+// ограничивает скорость всего потока (обратное давление), а память — n буферами.
+func fanOut(in <-chan LogEntry, n int, bufferSize int) []<-chan LogEntry {
+	outs := make([]chan LogEntry, n)
+	result := make([]<-chan LogEntry, n)
+	for i := range outs {
+		outs[i] = make(chan LogEntry, bufferSize)
+		result[i] = outs[i]
+	}
+
 	go func() {
-		defer close(out1)
-		defer close(out2)
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+
+		cases := make([]reflect.SelectCase, n)
 		for v := range in {
-			out1 <- v
-			out2 <- v
+			// Сначала отдаем запись всем, у кого есть место в буфере, без ожидания
+			pending := 0
+			for i, out := range outs {
+				select {
+				case out <- v:
+					cases[i] = reflect.SelectCase{Dir: reflect.SelectSend} // case без канала select пропускает
+				default:
+					cases[i] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(out), Send: reflect.ValueOf(v)}
+					pending++
+				}
+			}
+			// Остальным — по мере освобождения их буферов, в любом порядке
+			for ; pending > 0; pending-- {
+				chosen, _, _ := reflect.Select(cases)
+				cases[chosen] = reflect.SelectCase{Dir: reflect.SelectSend}
+			}
 		}
 	}()
-	return out1, out2
+
+	return result
+}
+
+// Запись лога в формате CSV исходной схемы (без завершающего перевода строки)
+func formatLogEntry(logEntry LogEntry) string {
+	return fmt.Sprintf("%s,%s,%s,%s,%d,%s", logEntry.Timestamp, logEntry.IP, logEntry.Method,
+		logEntry.URL, logEntry.StatusCode, formatMillis(logEntry.ResponseTime))
 }
 
-// Фильтрация логов: пропускаем только записи с statusCode >= minStatus
-func filterLogs(input <-chan LogEntry, minStatus int) <-chan LogEntry {
+// Время ответа без лишних нулей: 50 → "50", 0.427 → "0.427"
+func formatMillis(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', -1, 64)
+}
+
+// Выгрузка логов: каждая запись из input пишется в w в формате CSV
+// и передается дальше без изменений
+func dumpLogs(input <-chan LogEntry, w io.Writer) <-chan LogEntry {
 	out := make(chan LogEntry)
 
 	go func() {
 		defer close(out)
+		bw := bufio.NewWriter(w)
+		// Сбрасываем буфер до закрытия канала, чтобы после завершения pipeline все было записано
+		defer func() {
+			if err := bw.Flush(); err != nil {
+				log.Printf("ошибка выгрузки логов: %v", err)
+			}
+		}()
+
 		for logEntry := range input {
-			if logEntry.StatusCode >= minStatus {
+			fmt.Fprintln(bw, formatLogEntry(logEntry))
+			out <- logEntry
+		}
+	}()
+
+	return out
+}
+
+// Образцы записей: первые и последние разобранные записи (--head, --tail)
+type logSample struct {
+	Head []LogEntry
+	Tail []LogEntry
+}
+
+// Сохраняем первые head и последние tail записей из input в sample,
+// передавая записи дальше без изменений. Для последних записей используется
+// кольцевой буфер на tail элементов. sample заполняется до закрытия выходного канала.
+func sampleLogs(input <-chan LogEntry, head, tail int, sample *logSample) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		ring := make([]LogEntry, 0, tail)
+		next := 0 // позиция самой старой записи в заполненном кольцевом буфере
+		defer func() {
+			sample.Tail = append(ring[next:], ring[:next]...)
+		}()
+
+		for logEntry := range input {
+			if len(sample.Head) < head {
+				sample.Head = append(sample.Head, logEntry)
+			}
+			if tail > 0 {
+				if len(ring) < tail {
+					ring = append(ring, logEntry)
+				} else {
+					ring[next] = logEntry
+					next = (next + 1) % tail
+				}
+			}
+			out <- logEntry
+		}
+	}()
+
+	return out
+}
+
+// Нормализация HTTP метода: приводим Method к верхнему регистру,
+// чтобы get/Get/GET считались одним методом
+func normalizeMethods(input <-chan LogEntry) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		for logEntry := range input {
+			logEntry.Method = strings.ToUpper(logEntry.Method)
+			out <- logEntry
+		}
+	}()
+
+	return out
+}
+
+// Преобразование записей: каждая запись из input заменяется результатом fn
+func transformLogs(input <-chan LogEntry, fn func(LogEntry) LogEntry) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		for logEntry := range input {
+			out <- fn(logEntry)
+		}
+	}()
+
+	return out
+}
+
+// Декодирование URL: путь запроса раскодируется из percent-encoding
+// (/search%20a → /search a), чтобы эквивалентные URL считались вместе.
+// Query string не изменяется. Если путь раскодировать не удалось, URL остается
+// как есть, а количество таких записей сохраняется в *decodeErrors
+// (значение доступно после закрытия выходного канала).
+func decodeURLs(input <-chan LogEntry, decodeErrors *int) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		errorsCount := 0
+		defer func() { *decodeErrors = errorsCount }()
+
+		for logEntry := range input {
+			path, query, hasQuery := strings.Cut(logEntry.URL, "?")
+			decoded, err := url.PathUnescape(path)
+			if err != nil {
+				errorsCount++
+			} else {
+				if hasQuery {
+					decoded += "?" + query
+				}
+				logEntry.URL = decoded
+			}
+			out <- logEntry
+		}
+	}()
+
+	return out
+}
+
+// Условие отбора записей лога
+type logPredicate func(LogEntry) bool
+
+// Записи с кодом ответа не меньше minStatus
+func statusAtLeast(minStatus int) logPredicate {
+	return func(logEntry LogEntry) bool {
+		return logEntry.StatusCode >= minStatus
+	}
+}
+
+// Записи с кодом ответа из набора codes
+func statusIn(codes map[int]bool) logPredicate {
+	return func(logEntry LogEntry) bool {
+		return codes[logEntry.StatusCode]
+	}
+}
+
+// Записи с указанным HTTP методом (без учета регистра)
+func methodIs(method string) logPredicate {
+	return func(logEntry LogEntry) bool {
+		return strings.EqualFold(logEntry.Method, method)
+	}
+}
+
+// Записи с кодом ответа из класса class (2 — 2xx, 3 — 3xx и т.д.)
+func statusClassIs(class int) logPredicate {
+	return func(logEntry LogEntry) bool {
+		return statusClass(logEntry.StatusCode) == class
+	}
+}
+
+// Записи, URL которых соответствует регулярному выражению
+func urlMatches(pattern *regexp.Regexp) logPredicate {
+	return func(logEntry LogEntry) bool {
+		return pattern.MatchString(logEntry.URL)
+	}
+}
+
+// Отрицание условия
+func not(predicate logPredicate) logPredicate {
+	return func(logEntry LogEntry) bool {
+		return !predicate(logEntry)
+	}
+}
+
+// Объединение условий по И: запись проходит, только если выполнены все условия
+func allOf(predicates ...logPredicate) logPredicate {
+	return func(logEntry LogEntry) bool {
+		for _, predicate := range predicates {
+			if !predicate(logEntry) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Фильтрация логов: пропускаем только записи, удовлетворяющие условию match
+func filterLogs(input <-chan LogEntry, match logPredicate) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		for logEntry := range input {
+			if match(logEntry) {
 				out <- logEntry
 			}
 		}
@@ -193,57 +952,390 @@ func filterLogs(input <-chan LogEntry, minStatus int) <-chan LogEntry {
 	return out
 }
 
-// Подсчет статистики по логам из канала input
-func calculateStats(input <-chan LogEntry) Statistics {
-	stats := Statistics{
-		RequestsByIP: make(map[string]int),
+// Записи не раньше момента from (записи с нераспознанным временем не проходят)
+func timestampAtLeast(from time.Time) logPredicate {
+	return func(logEntry LogEntry) bool {
+		ts, err := time.Parse(timestampLayout, logEntry.Timestamp)
+		return err == nil && !ts.Before(from)
 	}
-	totalRespTime := 0
+}
 
-	for logEntry := range input {
-		stats.TotalRequests++
-		if logEntry.StatusCode >= 400 {
-			stats.ErrorCount++
+// Записи за последний период window относительно самой поздней записи во входных данных
+// (--since с --relative-to=max). Самое позднее время известно только в конце, поэтому
+// записи копятся в буфере и выдаются после окончания input. Записи, которые уже старше
+// window относительно наибольшего встреченного времени, в итог не попадут и из буфера
+// удаляются, так что в памяти остается примерно одно окно. Порядок записей сохраняется.
+func sinceLatest(input <-chan LogEntry, window time.Duration) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		type timedEntry struct {
+			ts    time.Time
+			entry LogEntry
+		}
+		var buffered []timedEntry
+		var latest time.Time
+		pruneAt := 1024 // размер буфера, при котором пора отбросить устаревшие записи
+
+		// Оставляем в буфере только записи не старше window от latest
+		prune := func() {
+			cutoff := latest.Add(-window)
+			buffered = slices.DeleteFunc(buffered, func(e timedEntry) bool {
+				return e.ts.Before(cutoff)
+			})
+		}
+
+		for logEntry := range input {
+			ts, err := time.Parse(timestampLayout, logEntry.Timestamp)
+			if err != nil {
+				continue
+			}
+			if ts.After(latest) {
+				latest = ts
+			}
+			if ts.Before(latest.Add(-window)) {
+				continue
+			}
+			buffered = append(buffered, timedEntry{ts, logEntry})
+			if len(buffered) >= pruneAt {
+				prune()
+				pruneAt = max(1024, 2*len(buffered))
+			}
+		}
+
+		prune()
+		for _, e := range buffered {
+			out <- e.entry
 		}
-		stats.RequestsByIP[logEntry.IP]++
-		totalRespTime += logEntry.ResponseTime
+	}()
+
+	return out
+}
+
+// Значение для группировки по параметру query string (--group-by-param).
+// Если параметра нет (или query string не разбирается), возвращается noParamValue;
+// у повторяющегося параметра берется первое значение.
+func queryParamValue(rawURL, name string) string {
+	_, query, ok := strings.Cut(rawURL, "?")
+	if !ok {
+		return noParamValue
+	}
+	query, _, _ = strings.Cut(query, "#")
+	values, err := url.ParseQuery(query)
+	if err != nil || !values.Has(name) {
+		return noParamValue
 	}
+	return values.Get(name)
+}
+
+// Группа для записей без параметра
+const noParamValue = "<none>"
+
+// Ключ URL для группировки: путь без query string и фрагмента, без завершающего "/".
+// Если prefixSegments > 0, оставляем только первые prefixSegments сегментов пути,
+// например при prefixSegments = 1 "/api/users/123?x=1" превращается в "/api".
+func urlKey(rawURL string, prefixSegments int) string {
+	path := rawURL
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimRight(path, "/")
 
-	if stats.TotalRequests > 0 {
-		stats.AverageRespTime = float64(totalRespTime) / float64(stats.TotalRequests)
+	if prefixSegments > 0 {
+		segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+		if len(segments) > prefixSegments {
+			segments = segments[:prefixSegments]
+		}
+		path = "/" + strings.Join(segments, "/")
 	}
 
-	return stats
+	if path == "" {
+		return "/"
+	}
+	return path
 }
 
-// Вывод топ-N IP адресов по количеству запросов
-func printTopIPs(requestsByIP map[string]int, n int) {
-	type ipCount struct {
-		ip    string
-		count int
+// Подсчет статистики по логам из канала input (логика накопления — в stats.go)
+func calculateStats(input <-chan LogEntry, opts Options) Statistics {
+	acc := newStatsAccumulator(opts)
+	for logEntry := range input {
+		acc.Add(logEntry)
 	}
+	return acc.Result()
+}
+
+// Пара "ключ — количество" для рейтингов топ-N
+type keyCount struct {
+	key   string
+	count int
+}
 
-	var ipCounts []ipCount
-	for ip, count := range requestsByIP {
-		ipCounts = append(ipCounts, ipCount{ip, count})
+// Выбираем до n ключей с наибольшим количеством, по убыванию количества.
+// При равном количестве ключи упорядочиваются по алфавиту, чтобы вывод был стабильным.
+// Если n <= 0, возвращаются все ключи.
+func topN(counts map[string]int, n int) []keyCount {
+	result := make([]keyCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, keyCount{key, count})
 	}
 
 	// Сортируем по убыванию количества запросов
-	for i := 0; i < len(ipCounts); i++ {
-		for j := i + 1; j < len(ipCounts); j++ {
-			if ipCounts[j].count > ipCounts[i].count {
-				ipCounts[j], ipCounts[i] = ipCounts[i], ipCounts[j]
-			}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].count != result[j].count {
+			return result[i].count > result[j].count
+		}
+		return result[i].key < result[j].key
+	})
+
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Разделитель групп разрядов в количествах, которые выводит отчет
+// (--thousands-sep, --locale). JSON, CSV и метрики всегда выводят числа без разделителей.
+var thousandsSep = ","
+
+// Разделители групп разрядов для --locale
+var localeThousandsSep = map[string]string{
+	"en": ",",
+	"de": ".",
+	"ru": " ",
+	"fr": " ",
+}
+
+// Количество с разделителем групп разрядов: 1234567 → "1,234,567"
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	if thousandsSep == "" {
+		return digits
+	}
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(thousandsSep)
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String()
+}
+
+// Доля part от total в процентах (0, если total равен нулю)
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// Вывод топ-N IP адресов по количеству запросов с долей от общего числа запросов total.
+// Рядом с IP выводится имя хоста из hostnames, если оно есть (--resolve-dns).
+func printTopIPs(w io.Writer, requestsByIP map[string]int, n int, total int, hostnames map[string]string) {
+	top := topN(requestsByIP, n)
+
+	fmt.Fprintf(w, "Топ %d IP адресов:\n", len(top))
+	covered := 0
+	for _, ip := range top {
+		fmt.Fprintf(w, "%s: %s запросов (%.1f%% от общего числа)\n", withHostname(ip.key, hostnames), formatCount(ip.count), percent(ip.count, total))
+		covered += ip.count
+	}
+	fmt.Fprintf(w, "Топ %d IP покрывают %.1f%% запросов\n", len(top), percent(covered, total))
+}
+
+// Вывод топ-N рейтинга, выбранного --top-by (для рейтингов по IP — с именами хостов из hostnames)
+func printTopBy(w io.Writer, stats Statistics, topBy string, n int, hostnames map[string]string) {
+	title, unit, values := topByRanking(stats, topBy)
+	top := topN(values, n)
+
+	fmt.Fprintf(w, "Топ %d %s:\n", len(top), title)
+	for _, kc := range top {
+		fmt.Fprintf(w, "%s: %s %s\n", withHostname(kc.key, hostnames), formatCount(kc.count), unit)
+	}
+}
+
+// Вывод топ-N URL по количеству запросов.
+// Если statusClasses не nil, для каждого URL выводится разбивка по классам статусов.
+func printTopURLs(w io.Writer, requestsByURL map[string]int, n int, statusClasses map[string]*statusClassCounts) {
+	top := topN(requestsByURL, n)
+
+	fmt.Fprintf(w, "Топ %d URL:\n", len(top))
+	for _, url := range top {
+		if counts, ok := statusClasses[url.key]; ok {
+			fmt.Fprintf(w, "%s: %s запросов (%s)\n", url.key, formatCount(url.count), counts)
+			continue
 		}
+		fmt.Fprintf(w, "%s: %s запросов\n", url.key, formatCount(url.count))
+	}
+}
+
+// Вывод топ-N эндпоинтов (метод + URL) по количеству запросов
+func printTopEndpoints(w io.Writer, requestsByEndpoint map[string]int, n int) {
+	top := topN(requestsByEndpoint, n)
+
+	fmt.Fprintf(w, "Топ %d эндпоинтов:\n", len(top))
+	for _, endpoint := range top {
+		fmt.Fprintf(w, "%s: %s запросов\n", endpoint.key, formatCount(endpoint.count))
 	}
+}
 
-	limit := n
-	if len(ipCounts) < n {
-		limit = len(ipCounts)
+// Вывод количества ответов по кодам статуса (или по классам, --status-granularity=class)
+// одной строкой по возрастанию кода: "Ответы по статусам: 200: 7, 404: 2, 500: 3"
+func printRequestsByStatus(w io.Writer, requestsByStatus map[int]int, byClass bool) {
+	var parts []string
+	for _, key := range slices.Sorted(maps.Keys(requestsByStatus)) {
+		parts = append(parts, fmt.Sprintf("%s: %s", statusLabel(key, byClass), formatCount(requestsByStatus[key])))
 	}
+	if len(parts) > 0 {
+		fmt.Fprintf(w, "Ответы по статусам: %s\n", strings.Join(parts, ", "))
+	}
+}
+
+// Вывод количества запросов по HTTP методам (по убыванию)
+func printRequestsByMethod(w io.Writer, requestsByMethod map[string]int) {
+	fmt.Fprintln(w, "Запросы по методам:")
+	for _, method := range topN(requestsByMethod, 0) {
+		fmt.Fprintf(w, "%s: %s запросов\n", method.key, formatCount(method.count))
+	}
+}
+
+// Вывод доли ответов из кэша CDN и количества ответов по статусам кэша (по убыванию).
+// Если колонки cache_status нет, ничего не выводится.
+func printCacheStatus(w io.Writer, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Доля ответов из кэша (HIT): %.1f%%\n", cacheHitRatio(counts)*100)
+	fmt.Fprintln(w, "Ответы по статусу кэша:")
+	for _, status := range topN(counts, 0) {
+		fmt.Fprintf(w, "%s: %s\n", status.key, formatCount(status.count))
+	}
+}
+
+// Вывод количества запросов по значениям параметра query string (по убыванию)
+func printRequestsByParam(w io.Writer, requestsByParam map[string]int, name string) {
+	fmt.Fprintf(w, "Запросы по параметру %s:\n", name)
+	for _, value := range topN(requestsByParam, 0) {
+		fmt.Fprintf(w, "%s: %s запросов\n", value.key, formatCount(value.count))
+	}
+}
+
+// Вывод периода, который охватывают логи. Если время ни одной записи
+// не удалось распознать, ничего не выводится.
+func printTimeSpan(w io.Writer, first, last time.Time) {
+	if first.IsZero() {
+		return
+	}
+	fmt.Fprintf(w, "Логи охватывают период с %s по %s (%v)\n",
+		first.Format(timestampLayout), last.Format(timestampLayout), last.Sub(first))
+}
+
+// Вывод периода, в который приходились ошибки (начало и конец инцидента)
+func printErrorWindow(w io.Writer, errorCount int, first, last time.Time) {
+	switch {
+	case errorCount == 0:
+		fmt.Fprintln(w, "Ошибок нет")
+	case first.IsZero():
+		fmt.Fprintln(w, "Время ошибок не распознано")
+	default:
+		fmt.Fprintf(w, "Первая ошибка: %s, последняя ошибка: %s\n", first.Format(timestampLayout), last.Format(timestampLayout))
+	}
+}
+
+// Вывод пиковой нагрузки за одну секунду
+func printPeakRate(w io.Writer, peakRate int, peakSecond time.Time) {
+	if peakRate == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Пиковая нагрузка за 1 с: %s запросов/с (%s)\n", formatCount(peakRate), peakSecond.Format(timestampLayout))
+}
+
+// Вывод пропусков в логах: периодов без единого запроса длиной от interval
+func printCoverageGaps(w io.Writer, gaps []timeGap, interval time.Duration) {
+	if len(gaps) == 0 {
+		fmt.Fprintf(w, "Пропусков в логах нет (интервалы по %v)\n", interval)
+		return
+	}
+	fmt.Fprintf(w, "Пропуски в логах (интервалы по %v без запросов): %d\n", interval, len(gaps))
+	for _, gap := range gaps {
+		fmt.Fprintf(w, "  %s — %s (%v)\n", gap.Start.Format(timestampLayout), gap.End.Format(timestampLayout), gap.End.Sub(gap.Start))
+	}
+}
+
+// Вывод всплесков нагрузки: интервалов, в которых запросов больше медианы в factor раз
+func printSpikes(w io.Writer, spikes []trafficSpike, interval time.Duration, factor float64) {
+	if len(spikes) == 0 {
+		fmt.Fprintf(w, "Всплесков нагрузки нет (интервалы по %v, порог — медиана × %g)\n", interval, factor)
+		return
+	}
+	fmt.Fprintf(w, "Всплески нагрузки (интервалы по %v, больше медианы × %g): %d\n", interval, factor, len(spikes))
+	for _, spike := range spikes {
+		fmt.Fprintf(w, "  %s: %s запросов (медиана %.1f)\n", spike.Start.Format(timestampLayout), formatCount(spike.Count), spike.Baseline)
+	}
+}
+
+// Вывод среднего времени ответа по классам статусов (только классы, в которых были запросы)
+func printAvgRespTimeByClass(w io.Writer, avgByClass [6]float64, counts statusClassCounts) {
+	var parts []string
+	for class := 1; class < len(counts); class++ {
+		if counts[class] > 0 {
+			parts = append(parts, fmt.Sprintf("%dxx: %.2f ms", class, avgByClass[class]))
+		}
+	}
+	if counts[0] > 0 {
+		parts = append(parts, fmt.Sprintf("other: %.2f ms", avgByClass[0]))
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(w, "Среднее время ответа по классам: %s\n", strings.Join(parts, ", "))
+	}
+}
+
+// Вывод аномалий задержки: количество записей выше порога и самые медленные из них
+func printLatencyAnomalies(w io.Writer, count int, threshold, sigma float64, worst []LogEntry) {
+	fmt.Fprintf(w, "Аномалии задержки (больше %.2f ms, среднее + %g·σ): %s\n", threshold, sigma, formatCount(count))
+	for _, e := range worst {
+		fmt.Fprintf(w, "  %s | %s | %s %s | %d | %s ms\n", e.Timestamp, e.IP, e.Method, e.URL, e.StatusCode, formatMillis(e.ResponseTime))
+	}
+}
+
+// Вывод образцов записей в читаемом виде
+func printEntries(title string, entries []LogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d):\n", title, len(entries))
+	for _, e := range entries {
+		fmt.Printf("  %s | %s | %s %s | %d | %s ms\n", e.Timestamp, e.IP, e.Method, e.URL, e.StatusCode, formatMillis(e.ResponseTime))
+	}
+}
+
+// Вывод распределения запросов по часам суток: таблица из 24 строк с количеством
+// и полосой, длина которой пропорциональна количеству запросов
+func printHourHistogram(w io.Writer, requestsByHour [24]int) {
+	const barWidth = 40
+	busiest := slices.Max(requestsByHour[:])
+	if busiest == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "Запросы по часам суток:")
+	for hour, count := range requestsByHour {
+		line := fmt.Sprintf("%02d:00 %8s %s", hour, formatCount(count), strings.Repeat("#", count*barWidth/busiest))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
 
-	fmt.Printf("Топ %d IP адресов:\n", limit)
-	for i := 0; i < limit; i++ {
-		fmt.Printf("%s: %d запросов\n", ipCounts[i].ip, ipCounts[i].count)
+// Вывод распределения записей между воркерами пула
+func printWorkerCounts(w io.Writer, workerCounts []int) {
+	parts := make([]string, len(workerCounts))
+	for i, count := range workerCounts {
+		parts[i] = fmt.Sprintf("воркер %d: %s", i, formatCount(count))
 	}
+	fmt.Fprintf(w, "Распределение по воркерам: %s\n", strings.Join(parts, ", "))
 }
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLine(t *testing.T) {
+	extraSchema := defaultSchema()
+	extraSchema.allowExtra = true
+
+	tests := []struct {
+		name      string
+		line      string
+		schema    logSchema
+		want      LogEntry
+		wantField string // поле ParseError; пусто — ошибки нет или не совпало количество полей
+		wantErr   string // фрагмент текста ошибки; пусто — ошибки нет
+	}{
+		{
+			name:   "корректная строка",
+			line:   "2024-01-15 10:30:00,192.168.1.100,GET,/api/users,200,150",
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "192.168.1.100", Method: "GET", URL: "/api/users", StatusCode: 200, ResponseTime: 150},
+		},
+		{
+			name:   "статус ровно 400",
+			line:   "2024-01-15 10:30:00,10.0.0.1,GET,/a,400,15",
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 400, ResponseTime: 15},
+		},
+		{
+			name:   "нулевое время ответа",
+			line:   "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,0",
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200},
+		},
+		{
+			name:   "дробное время ответа",
+			line:   "2024-01-15 10:30:00,10.0.0.1,POST,/a,201,0.427",
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "POST", URL: "/a", StatusCode: 201, ResponseTime: 0.427},
+		},
+		{
+			// Отрицательные значения разбираются как есть: их отбрасывают
+			// --min-response-time и классификация статусов (класс вне 1xx–5xx)
+			name:   "отрицательные статус и время ответа",
+			line:   "2024-01-15 10:30:00,10.0.0.1,GET,/a,-1,-5",
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: -1, ResponseTime: -5},
+		},
+		{
+			name:   "пустые текстовые поля",
+			line:   "2024-01-15 10:30:00,,,,200,15",
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", StatusCode: 200, ResponseTime: 15},
+		},
+		{
+			name:   "пробелы по краям значений",
+			line:   "2024-01-15 10:30:00, 10.0.0.1 , GET ,/a, 200 , 15 ",
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200, ResponseTime: 15},
+		},
+		{
+			name:    "мало полей",
+			line:    "2024-01-15 10:30:00,10.0.0.1,GET,/a,200",
+			schema:  defaultSchema(),
+			wantErr: "неверный формат логов в строке 7: ожидалось полей 6, получено 5",
+		},
+		{
+			name:    "лишние поля",
+			line:    "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15,extra",
+			schema:  defaultSchema(),
+			wantErr: "неверный формат логов в строке 7: ожидалось полей 6, получено 7",
+		},
+		{
+			name:    "пустая строка",
+			line:    "",
+			schema:  defaultSchema(),
+			wantErr: "ожидалось полей 6, получено 1",
+		},
+		{
+			name:   "лишние поля с --allow-extra-fields",
+			line:   "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15,extra,more",
+			schema: extraSchema,
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200, ResponseTime: 15},
+		},
+		{
+			name:      "статус не число",
+			line:      "2024-01-15 10:30:00,10.0.0.1,GET,/a,OK,15",
+			schema:    defaultSchema(),
+			wantField: "status",
+			wantErr:   `неверный код ответа в строке 7: strconv.Atoi: parsing "OK"`,
+		},
+		{
+			name:      "пустой статус",
+			line:      "2024-01-15 10:30:00,10.0.0.1,GET,/a,,15",
+			schema:    defaultSchema(),
+			wantField: "status",
+			wantErr:   `неверный код ответа в строке 7: strconv.Atoi: parsing ""`,
+		},
+		{
+			name:      "время ответа не число",
+			line:      "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,fast",
+			schema:    defaultSchema(),
+			wantField: "response_time",
+			wantErr:   `неверное время ответа в строке 7: strconv.ParseFloat: parsing "fast"`,
+		},
+		{
+			name:      "пустое время ответа",
+			line:      "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,",
+			schema:    defaultSchema(),
+			wantField: "response_time",
+			wantErr:   `неверное время ответа в строке 7: strconv.ParseFloat: parsing ""`,
+		},
+		{
+			name:      "время ответа NaN",
+			line:      "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,NaN",
+			schema:    defaultSchema(),
+			wantField: "response_time",
+			wantErr:   "неверное время ответа в строке 7: время ответа должно быть конечным числом",
+		},
+		{
+			name:   "BOM и \\r",
+			line:   cleanLine("\ufeff2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15\r"),
+			schema: defaultSchema(),
+			want:   LogEntry{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200, ResponseTime: 15},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLogLine(tt.line, 6, tt.schema)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("неожиданная ошибка: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("parseLogLine() = %+v, ожидалось %+v", got, tt.want)
+				}
+				return
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ошибка %v (%T), ожидалась *ParseError", err, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ошибка %q не содержит %q", err.Error(), tt.wantErr)
+			}
+			if got != (LogEntry{}) {
+				t.Errorf("при ошибке возвращена запись %+v", got)
+			}
+			if parseErr.Line != 7 {
+				t.Errorf("Line = %d, ожидалось 7", parseErr.Line)
+			}
+			if parseErr.Field != tt.wantField {
+				t.Errorf("Field = %q, ожидалось %q", parseErr.Field, tt.wantField)
+			}
+			if parseErr.Raw != tt.line {
+				t.Errorf("Raw = %q, ожидалось %q", parseErr.Raw, tt.line)
+			}
+		})
+	}
+}
+
+func TestCleanLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"a,b,c", "a,b,c"},
+		{"\ufeffa,b,c", "a,b,c"},
+		{"a,b,c\r", "a,b,c"},
+		{"\ufeffa,b,c\r", "a,b,c"},
+		{"a,\ufeffb,c", "a,\ufeffb,c"}, // BOM только в начале строки
+	}
+	for _, tt := range tests {
+		if got := cleanLine(tt.line); got != tt.want {
+			t.Errorf("cleanLine(%q) = %q, ожидалось %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// Разбор произвольных строк: без паники, все ошибки — *ParseError, а строка с лишними
+// полями (разделение ограничено количеством колонок схемы) всегда отклоняется
+func FuzzParseLogLine(f *testing.F) {
+	f.Add("2024-01-15 10:30:00,192.168.1.100,GET,/api/users,200,150")
+	f.Add("2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15,extra")
+	f.Add("2024-01-15 10:30:00,10.0.0.1,GET,/a,OK,NaN")
+	f.Add(strings.Repeat(",", 1000))
+	f.Add("")
+
+	schema := defaultSchema()
+	f.Fuzz(func(t *testing.T, line string) {
+		_, err := parseLogLine(line, 0, schema)
+		var parseErr *ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			t.Fatalf("ошибка %v (%T), ожидалась *ParseError", err, err)
+		}
+		if strings.Count(line, schema.delimiter)+1 > schema.columns {
+			if parseErr == nil {
+				t.Fatalf("строка с лишними полями разобрана без ошибки: %q", line)
+			}
+			if parseErr.Field != "" {
+				t.Fatalf("Field = %q, ожидалась ошибка количества полей", parseErr.Field)
+			}
+		}
+	})
+}
+
+// Все записи из канала (до его закрытия)
+func collectEntries(input <-chan LogEntry) []LogEntry {
+	var entries []LogEntry
+	for logEntry := range input {
+		entries = append(entries, logEntry)
+	}
+	return entries
+}
+
+func TestNormalizeMethods(t *testing.T) {
+	input := []LogEntry{{Method: "get"}, {Method: "Post"}, {Method: "DELETE"}}
+	var got []string
+	for _, logEntry := range collectEntries(normalizeMethods(entriesChan(input, 0))) {
+		got = append(got, logEntry.Method)
+	}
+	want := []string{"GET", "POST", "DELETE"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("методы = %v, ожидалось %v", got, want)
+	}
+}
+
+// get, Get и GET считаются одним методом, если нормализация не отключена
+func TestRequestsByMethodNormalized(t *testing.T) {
+	logs := testLogsHeader +
+		"2024-01-15 10:30:00,10.0.0.1,get,/a,200,10\n" +
+		"2024-01-15 10:30:01,10.0.0.1,Get,/a,200,10\n" +
+		"2024-01-15 10:30:02,10.0.0.1,GET,/a,200,10\n" +
+		"2024-01-15 10:30:03,10.0.0.1,post,/a,200,10\n"
+
+	tests := []struct {
+		normalize bool
+		want      map[string]int
+	}{
+		{true, map[string]int{"GET": 3, "POST": 1}},
+		{false, map[string]int{"get": 1, "Get": 1, "GET": 1, "post": 1}},
+	}
+	for _, tt := range tests {
+		opts := defaultOptions()
+		opts.NormalizeMethod = tt.normalize
+		stats, err := runPipeline(t.Context(), strings.NewReader(logs), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(stats.RequestsByMethod, tt.want) {
+			t.Errorf("NormalizeMethod=%v: RequestsByMethod = %v, ожидалось %v", tt.normalize, stats.RequestsByMethod, tt.want)
+		}
+	}
+}
+
+// Файл из Windows: BOM перед заголовком и \r\n в конце строк
+func TestReadLogsBOMAndCRLF(t *testing.T) {
+	logs := "\ufefftimestamp,ip,method,url,status,response_time\r\n" +
+		"2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\r\n" +
+		"2024-01-15 10:30:01,10.0.0.2,GET,/b,404,20\r\n"
+	var skipped skippedLines
+	input, err := readLogs(t.Context(), strings.NewReader(logs), defaultOptions(), &skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := collectEntries(input)
+	if skipped.Total != 0 {
+		t.Errorf("пропущено строк: %s", skipped)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("записей %d, ожидалось 2", len(entries))
+	}
+	if entries[1].URL != "/b" || entries[1].ResponseTime != 20 {
+		t.Errorf("запись %+v, ожидались URL /b и время 20", entries[1])
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	get500 := LogEntry{Method: "GET", StatusCode: 500}
+	post404 := LogEntry{Method: "post", StatusCode: 404}
+	get200 := LogEntry{Method: "GET", StatusCode: 200}
+
+	tests := []struct {
+		name      string
+		predicate logPredicate
+		want      []bool // для get500, post404, get200
+	}{
+		{"statusAtLeast(500)", statusAtLeast(500), []bool{true, false, false}},
+		{"statusAtLeast(400)", statusAtLeast(400), []bool{true, true, false}},
+		{"statusIn(404, 500)", statusIn(map[int]bool{404: true, 500: true}), []bool{true, true, false}},
+		{"methodIs(POST)", methodIs("POST"), []bool{false, true, false}},
+		{"statusClassIs(2)", statusClassIs(2), []bool{false, false, true}},
+		{"not(methodIs(GET))", not(methodIs("GET")), []bool{false, true, false}},
+		{"allOf(>=400, GET)", allOf(statusAtLeast(400), methodIs("get")), []bool{true, false, false}},
+		{"allOf()", allOf(), []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		for i, logEntry := range []LogEntry{get500, post404, get200} {
+			if got := tt.predicate(logEntry); got != tt.want[i] {
+				t.Errorf("%s(%+v) = %v, ожидалось %v", tt.name, logEntry, got, tt.want[i])
+			}
+		}
+	}
+}
+
+// Фильтр выгрузки: --status-min и --method проверяются вместе
+func TestFilterLogs(t *testing.T) {
+	input := []LogEntry{
+		{Method: "GET", StatusCode: 500, URL: "/a"},
+		{Method: "POST", StatusCode: 503, URL: "/b"},
+		{Method: "GET", StatusCode: 404, URL: "/c"},
+		{Method: "GET", StatusCode: 200, URL: "/d"},
+	}
+	opts := defaultOptions()
+	opts.FilterMinStatus = 500
+	opts.FilterMethod = "get"
+	got := collectEntries(filterLogs(entriesChan(input, 0), logFilter(opts)))
+	if len(got) != 1 || got[0].URL != "/a" {
+		t.Errorf("отобраны %+v, ожидалась только запись /a", got)
+	}
+}
+
+// Читаем логи через readLogs: разобранные записи и пропущенные строки
+func readTestLogs(t *testing.T, logs string, opts Options) ([]LogEntry, skippedLines) {
+	t.Helper()
+	var skipped skippedLines
+	input, err := readLogs(t.Context(), strings.NewReader(logs), opts, &skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return collectEntries(input), skipped
+}
+
+func TestAutoHeader(t *testing.T) {
+	const data = "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n2024-01-15 10:30:01,10.0.0.2,GET,/b,404,20\n"
+	tests := []struct {
+		name string
+		logs string
+		want int
+	}{
+		{"без заголовка", data, 2},
+		{"с заголовком", testLogsHeader + data, 2},
+		{"заголовок второго файла", testLogsHeader + data + testLogsHeader + data, 4},
+		{"второй файл без заголовка", data + testLogsHeader + data, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.AutoHeader = true
+			entries, skipped := readTestLogs(t, tt.logs, opts)
+			if len(entries) != tt.want || skipped.Total != 0 {
+				t.Errorf("записей %d, пропущено %d, ожидалось %d и 0", len(entries), skipped.Total, tt.want)
+			}
+		})
+	}
+}
+
+// Без --auto-header первая строка данных принимается за заголовок и теряется
+func TestWithoutAutoHeader(t *testing.T) {
+	entries, _ := readTestLogs(t, "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n2024-01-15 10:30:01,10.0.0.2,GET,/b,404,20\n", defaultOptions())
+	if len(entries) != 1 {
+		t.Errorf("записей %d, ожидалась 1", len(entries))
+	}
+}
+
+// Количество колонок берется из схемы (заголовка), а не задано жестко
+func TestFieldCount(t *testing.T) {
+	tests := []struct {
+		name        string
+		logs        string
+		allowExtra  bool
+		want        []LogEntry
+		wantSkipped int
+	}{
+		{
+			name:        "5 колонок при 6 в заголовке",
+			logs:        testLogsHeader + "2024-01-15 10:30:00,10.0.0.1,GET,/a,200\n",
+			wantSkipped: 1,
+		},
+		{
+			name: "6 колонок",
+			logs: testLogsHeader + "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15\n",
+			want: []LogEntry{{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200, ResponseTime: 15}},
+		},
+		{
+			name: "8 колонок в заголовке",
+			logs: "timestamp,ip,method,url,status,response_time,bytes,user_agent\n" +
+				"2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15,512,curl/8.0\n",
+			want: []LogEntry{{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200, ResponseTime: 15, Bytes: 512, UserAgent: "curl/8.0"}},
+		},
+		{
+			name: "8 колонок в заголовке, 2 неизвестные",
+			logs: "region,timestamp,ip,method,url,status,response_time,trace_id\n" +
+				"eu,2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15,abc\n",
+			want: []LogEntry{{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200, ResponseTime: 15}},
+		},
+		{
+			name:        "8 колонок при 6 в заголовке",
+			logs:        testLogsHeader + "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15,512,curl/8.0\n",
+			wantSkipped: 1,
+		},
+		{
+			name:       "8 колонок при 6 в заголовке с --allow-extra-fields",
+			logs:       testLogsHeader + "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15,512,curl/8.0\n",
+			allowExtra: true,
+			want:       []LogEntry{{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/a", StatusCode: 200, ResponseTime: 15}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultOptions()
+			opts.AllowExtraFields = tt.allowExtra
+			entries, skipped := readTestLogs(t, tt.logs, opts)
+			if !reflect.DeepEqual(entries, tt.want) {
+				t.Errorf("записи %+v, ожидалось %+v", entries, tt.want)
+			}
+			if skipped.Total != tt.wantSkipped {
+				t.Errorf("пропущено строк %d, ожидалось %d", skipped.Total, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+// Пробелы по краям значений не дробят статистику; с --no-trim значения остаются как есть
+func TestTrimFields(t *testing.T) {
+	const logs = testLogsHeader +
+		"2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n" +
+		"2024-01-15 10:30:01, 10.0.0.1 , GET ,/a ,200, 20\n" +
+		"2024-01-15 10:30:02,10.0.0.1,  GET,/a, 404 ,30\n"
+
+	opts := defaultOptions()
+	entries, skipped := readTestLogs(t, logs, opts)
+	if skipped.Total != 0 {
+		t.Fatalf("пропущено строк %d, ожидалось 0", skipped.Total)
+	}
+	stats := calculateTestStats(entries, opts)
+	if !reflect.DeepEqual(stats.RequestsByMethod, map[string]int{"GET": 3}) {
+		t.Errorf("RequestsByMethod = %v, ожидалось map[GET:3]", stats.RequestsByMethod)
+	}
+	if !reflect.DeepEqual(stats.RequestsByIP, map[string]int{"10.0.0.1": 3}) {
+		t.Errorf("RequestsByIP = %v, ожидалось map[10.0.0.1:3]", stats.RequestsByIP)
+	}
+	if !reflect.DeepEqual(stats.RequestsByURL, map[string]int{"/a": 3}) {
+		t.Errorf("RequestsByURL = %v, ожидалось map[/a:3]", stats.RequestsByURL)
+	}
+
+	// С --no-trim пробелы значимы: строки с пробелами в числовых колонках не
+	// разбираются, а текстовые значения различаются
+	opts.NoTrim = true
+	entries, skipped = readTestLogs(t, logs, opts)
+	if skipped.Total != 2 {
+		t.Errorf("с --no-trim пропущено строк %d, ожидалось 2", skipped.Total)
+	}
+	entries, _ = readTestLogs(t, testLogsHeader+"2024-01-15 10:30:00, 10.0.0.1, GET ,/a,200,10\n", opts)
+	if len(entries) != 1 || entries[0].IP != " 10.0.0.1" || entries[0].Method != " GET " {
+		t.Errorf("с --no-trim записи %+v, ожидались значения с пробелами", entries)
+	}
+}
+
+// Целое и дробное время ответа разбирается без потерь и одинаково участвует в
+// среднем, перцентилях и выгрузке
+func TestFractionalResponseTime(t *testing.T) {
+	const logs = testLogsHeader +
+		"2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n" +
+		"2024-01-15 10:30:01,10.0.0.1,GET,/a,200,0.427\n" +
+		"2024-01-15 10:30:02,10.0.0.1,GET,/a,200,0.073\n" +
+		"2024-01-15 10:30:03,10.0.0.1,GET,/a,200,2.5\n"
+
+	opts := defaultOptions()
+	entries, skipped := readTestLogs(t, logs, opts)
+	if skipped.Total != 0 {
+		t.Fatalf("пропущено строк %d, ожидалось 0", skipped.Total)
+	}
+	var times []float64
+	for _, logEntry := range entries {
+		times = append(times, logEntry.ResponseTime)
+	}
+	if want := []float64{10, 0.427, 0.073, 2.5}; !reflect.DeepEqual(times, want) {
+		t.Fatalf("время ответа %v, ожидалось %v", times, want)
+	}
+
+	stats := calculateTestStats(entries, opts)
+	if math.Abs(stats.AverageRespTime-3.25) > 1e-9 {
+		t.Errorf("AverageRespTime = %g, ожидалось 3.25", stats.AverageRespTime)
+	}
+	counts := map[float64]int{}
+	for _, respTime := range times {
+		counts[respTime]++
+	}
+	if p50 := percentileOfCounts(counts, len(times), 50); p50 != 0.427 {
+		t.Errorf("p50 = %g, ожидалось 0.427", p50)
+	}
+
+	// Целые значения выгружаются без дробной части, дробные — без округления
+	for respTime, want := range map[float64]string{10: "10", 0.427: "0.427", 2.5: "2.5"} {
+		if got := formatMillis(respTime); got != want {
+			t.Errorf("formatMillis(%g) = %q, ожидалось %q", respTime, got, want)
+		}
+	}
+}
+
+// С --multiline строки, которые не начинаются с времени, продолжают предыдущую запись
+func TestMultilineRecords(t *testing.T) {
+	const logs = "timestamp,ip,method,url,status,response_time,error\n" +
+		"2024-01-15 10:30:00,10.0.0.1,GET,/a,500,15,java.lang.NullPointerException\n" +
+		"\tat com.example.Handler.handle(Handler.java:42)\n" +
+		"\tat com.example.Server.run(Server.java:7)\n" +
+		"2024-01-15 10:30:01,10.0.0.2,GET,/b,200,20,\n" +
+		"2024-01-15 10:30:02,10.0.0.3,POST,/c,503,30,timeout\n" +
+		"\tcaused by: upstream\n"
+
+	opts := defaultOptions()
+	opts.RecordStart = defaultRecordStart
+	entries, skipped := readTestLogs(t, logs, opts)
+	if skipped.Total != 0 {
+		t.Errorf("пропущено строк %d, ожидалось 0", skipped.Total)
+	}
+	var urls []string
+	for _, logEntry := range entries {
+		urls = append(urls, logEntry.URL)
+	}
+	if want := []string{"/a", "/b", "/c"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("записи %v, ожидалось %v", urls, want)
+	}
+
+	// Без --multiline строки продолжения разбираются отдельно и пропускаются
+	opts.RecordStart = nil
+	entries, skipped = readTestLogs(t, logs, opts)
+	if len(entries) != 3 || skipped.Total != 3 {
+		t.Errorf("без --multiline записей %d и пропущено %d, ожидалось 3 и 3", len(entries), skipped.Total)
+	}
+}
+
+// Запись, к которой приклеилось лишнее продолжение, пропускается целиком как одна строка
+func TestMultilineParseError(t *testing.T) {
+	const logs = testLogsHeader +
+		"2024-01-15 10:30:00,10.0.0.1,GET,/a,200,15\n" +
+		"2024-01-15 10:30:01,10.0.0.2,GET,/b,200,20\n" +
+		"continuation\n"
+
+	opts := defaultOptions()
+	opts.RecordStart = defaultRecordStart
+	entries, skipped := readTestLogs(t, logs, opts)
+	if len(entries) != 1 || skipped.Total != 1 {
+		t.Errorf("записей %d и пропущено %d, ожидалось 1 и 1", len(entries), skipped.Total)
+	}
+}
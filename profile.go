@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Запускаем профилирование: CPU профиль пишется в cpuPath с момента вызова,
+// профиль памяти — в memPath при вызове возвращаемой функции stop (пустой путь —
+// профиль не нужен). stop останавливает профилирование и записывает файлы;
+// повторные вызовы ничего не делают, поэтому ее можно и отложить через defer,
+// и вызвать явно перед os.Exit.
+func startProfiling(cpuPath, memPath string) (stop func(), err error) {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		cpuFile, err = os.Create(cpuPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, err
+		}
+	}
+
+	stopped := false
+	stop = func() {
+		if stopped {
+			return
+		}
+		stopped = true
+
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				log.Printf("ошибка записи CPU профиля: %v", err)
+			}
+		}
+		if memPath != "" {
+			if err := writeHeapProfile(memPath); err != nil {
+				log.Printf("ошибка записи профиля памяти: %v", err)
+			}
+		}
+	}
+	return stop, nil
+}
+
+// Записываем профиль памяти (после сборки мусора, чтобы статистика была актуальной)
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
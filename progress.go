@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Как часто обновляется строка прогресса
+const progressInterval = time.Second
+
+// Прогресс чтения входных данных (--progress): сколько байтов прочитано из файлов
+// и сколько всего. Считаются байты файлов на диске (до распаковки), поэтому доля
+// прочитанного верна и для сжатых файлов. Счетчик атомарный: читает его горутина
+// вывода, пока pipeline продолжает чтение.
+type progressCounter struct {
+	read  atomic.Int64
+	total int64 // суммарный размер локальных файлов (0 — неизвестен)
+}
+
+// Размер локальных входных файлов. URL, Kafka и сокеты не учитываются: их размер
+// заранее неизвестен. Если среди входных данных есть такой источник, возвращается 0
+// (доля прочитанного и оставшееся время не выводятся).
+func inputSize(names []string) int64 {
+	var total int64
+	for _, name := range names {
+		if isHTTPURL(name) || isKafkaInput(name) || isSocketInput(name) {
+			return 0
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return 0
+		}
+		total += info.Size()
+	}
+	return total
+}
+
+// Считает байты, прочитанные из r
+type countingReader struct {
+	r       io.Reader
+	counter *progressCounter
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.counter.read.Add(int64(n))
+	return n, err
+}
+
+// Выводим прогресс в w раз в progressInterval, перезаписывая строку на месте.
+// stop выводит итоговую строку и завершает вывод; повторные вызовы ничего не делают.
+func (c *progressCounter) start(w io.Writer) (stop func()) {
+	started := time.Now()
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(w, "\r\x1b[K%s", c.line(time.Since(started)))
+			case <-done:
+				fmt.Fprintf(w, "\r\x1b[K%s\n", c.line(time.Since(started)))
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
+
+// Строка прогресса: прочитано, скорость и, если размер известен, доля и оставшееся время.
+// Оставшееся время — по средней скорости с начала чтения.
+func (c *progressCounter) line(elapsed time.Duration) string {
+	read := c.read.Load()
+	speed := float64(read) / elapsed.Seconds()
+	if c.total <= 0 {
+		return fmt.Sprintf("Прочитано %s, %s/с", formatBytes(read), formatBytes(int64(speed)))
+	}
+	line := fmt.Sprintf("Прочитано %s из %s (%.1f%%), %s/с", formatBytes(read), formatBytes(c.total),
+		float64(read)*100/float64(c.total), formatBytes(int64(speed)))
+	if speed > 0 && read < c.total {
+		eta := time.Duration(float64(c.total-read) / speed * float64(time.Second))
+		line += fmt.Sprintf(", осталось ~%v", eta.Round(time.Second))
+	}
+	return line
+}
+
+// Размер в байтах с единицей из byteUnits: 1536 → "1.5 KB"
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
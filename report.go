@@ -0,0 +1,534 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Формат отчета по статистике (--format). Все форматы получают одни и те же данные:
+// посчитанную статистику и настройки запуска, по которым видно, какие показатели выбраны.
+type ReportWriter interface {
+	WriteReport(w io.Writer, stats Statistics, opts Options)
+}
+
+// Функция вывода отчета как ReportWriter
+type reportWriterFunc func(w io.Writer, stats Statistics, opts Options)
+
+func (f reportWriterFunc) WriteReport(w io.Writer, stats Statistics, opts Options) {
+	f(w, stats, opts)
+}
+
+// Форматы отчета для --format
+var reportWriters = map[string]ReportWriter{
+	"text":     reportWriterFunc(writeTextReport),
+	"compact":  reportWriterFunc(writeCompactReport),
+	"markdown": reportWriterFunc(writeMarkdownReport),
+}
+
+// Названия форматов в порядке вывода в справке
+var reportFormats = []string{"text", "compact", "markdown"}
+
+// Текстовый отчет (--format=text, по умолчанию): показатели с пояснениями на русском,
+// только выбранные в opts.Stats и включенные флагами
+func writeTextReport(w io.Writer, stats Statistics, opts Options) {
+	// Выводим результаты подсчёта
+	if opts.Stats.has(statTotal) {
+		fmt.Fprintf(w, "Всего запросов: %s\n", formatCount(stats.TotalRequests))
+	}
+	if opts.Stats.has(statErrors) {
+		if len(opts.ErrorCodes) > 0 {
+			fmt.Fprintf(w, "Всего ошибок (%s): %s\n", errorDefinition(opts), formatCount(stats.ErrorCount))
+		} else {
+			fmt.Fprintf(w, "Всего ошибок (4xx and 5xx): %s\n", formatCount(stats.ErrorCount))
+		}
+		printErrorWindow(w, stats.ErrorCount, stats.FirstErrorAt, stats.LastErrorAt)
+	}
+	if opts.Stats.has(statStatusClasses) {
+		fmt.Fprintf(w, "Успешных ответов (2xx): %s\n", formatCount(stats.SuccessCount))
+		fmt.Fprintf(w, "Перенаправлений (3xx): %s\n", formatCount(stats.RedirectCount))
+		printRequestsByStatus(w, stats.RequestsByStatus, opts.StatusByClass)
+	}
+	if opts.Stats.has(statAvgTime) {
+		fmt.Fprintf(w, "Среднее время ответа: %.2f ms\n", stats.AverageRespTime)
+		fmt.Fprintf(w, "Стандартное отклонение времени ответа: %.2f ms\n", stats.StdDevRespTime)
+		printAvgRespTimeByClass(w, stats.AvgRespTimeByClass, stats.RequestsByClass)
+	}
+	if opts.SlowThreshold > 0 {
+		fmt.Fprintf(w, "Медленных запросов (дольше %v): %s (%.1f%%)\n", opts.SlowThreshold, formatCount(stats.SlowCount), percent(stats.SlowCount, stats.TotalRequests))
+	}
+	if opts.FastThreshold > 0 {
+		fmt.Fprintf(w, "Быстрых запросов (быстрее %v): %s (%.1f%%)\n", opts.FastThreshold, formatCount(stats.FastCount), percent(stats.FastCount, stats.TotalRequests))
+	}
+	if len(opts.SLOs) > 0 {
+		printSLOCompliance(w, stats.SLOResults)
+	}
+	if opts.AnomalySigma > 0 {
+		printLatencyAnomalies(w, stats.AnomalyCount, stats.AnomalyThreshold, opts.AnomalySigma, stats.Anomalies)
+	}
+
+	// Выводим период, который охватывают логи
+	if opts.Stats.has(statTimeSpan) {
+		printTimeSpan(w, stats.FirstTimestamp, stats.LastTimestamp)
+	}
+	if opts.Stats.has(statPeakRate) {
+		printPeakRate(w, stats.PeakRate, stats.PeakSecond)
+	}
+	if opts.Stats.has(statHourOfDay) {
+		printHourHistogram(w, stats.RequestsByHour)
+	}
+	if opts.GapInterval > 0 {
+		printCoverageGaps(w, stats.CoverageGaps, opts.GapInterval)
+	}
+	if opts.SpikeFactor > 0 {
+		printSpikes(w, stats.Spikes, opts.SpikeInterval, opts.SpikeFactor)
+	}
+
+	// С --top-by выводим только выбранный рейтинг вместо топ IP и топ URL
+	if opts.TopBy != "" {
+		printTopBy(w, stats, opts.TopBy, 5, opts.Hostnames)
+	}
+
+	// Выводим топ IP адресов по количеству запросов
+	// В данном случае Топ 5
+	if opts.Stats.has(statTopIPs) && opts.TopBy == "" {
+		printTopIPs(w, stats.RequestsByIP, 5, stats.TotalRequests, opts.Hostnames)
+		fmt.Fprintf(w, "Коэффициент Джини по IP: %.2f (0 — запросы распределены поровну, ближе к 1 — сосредоточены на немногих IP)\n", stats.IPGini)
+	}
+
+	// Выводим топ URL по количеству запросов
+	if opts.Stats.has(statTopURLs) && opts.TopBy == "" {
+		printTopURLs(w, stats.RequestsByURL, 5, stats.URLStatusClasses)
+	}
+
+	// Выводим топ эндпоинтов (метод + URL)
+	if opts.Stats.has(statTopEndpoints) {
+		printTopEndpoints(w, stats.RequestsByEndpoint, opts.TopEndpoints)
+	}
+
+	// Выводим распределение запросов по HTTP методам
+	if opts.Stats.has(statMethods) {
+		printRequestsByMethod(w, stats.RequestsByMethod)
+	}
+
+	// Выводим долю ответов из кэша CDN (только если в схеме есть колонка cache_status)
+	if opts.Stats.has(statCacheStatus) {
+		printCacheStatus(w, stats.CacheStatusCounts)
+	}
+
+	// Выводим распределение запросов по значениям параметра query string
+	if opts.GroupByParam != "" {
+		printRequestsByParam(w, stats.RequestsByParam, opts.GroupByParam)
+	}
+
+	// Выводим ответы 5xx вместе с сообщениями из журнала ошибок
+	printErrorCorrelations(w, stats.ErrorCorrelations)
+
+	// Сообщаем о нераспознанных строках и причинах, по которым они не разобраны
+	if stats.SkippedLines.Total > 0 {
+		fmt.Fprintf(w, "Пропущено строк: %s\n", stats.SkippedLines)
+	}
+
+	// Сколько записей отброшено как выбросы времени ответа
+	if hasResponseTimeRange(opts) {
+		fmt.Fprintf(w, "Отброшено записей с временем ответа вне %s: %s\n", describeResponseTimeRange(opts), formatCount(stats.ResponseTimeOutliers))
+	}
+
+	// Предупреждаем об URL, которые не удалось раскодировать
+	if stats.URLDecodeErrors > 0 {
+		fmt.Fprintf(w, "Предупреждение: не удалось раскодировать URL: %s\n", formatCount(stats.URLDecodeErrors))
+	}
+
+	// Выводим распределение записей между воркерами
+	if opts.WorkerStats {
+		printWorkerCounts(w, stats.WorkerCounts)
+	}
+}
+
+// Компактный отчет: каждый показатель — отдельная строка "key: value" на английском,
+// без заголовков и разделителей разрядов. Порядок строк постоянный: показатели идут
+// в порядке объявления, элементы рейтингов — по убыванию количества (при равенстве по ключу),
+// поэтому вывод удобно искать grep и сравнивать между запусками.
+func writeCompactReport(w io.Writer, stats Statistics, opts Options) {
+	line := func(key string, value any) {
+		fmt.Fprintf(w, "%s: %v\n", key, value)
+	}
+	ranking := func(prefix string, counts map[string]int, n int) {
+		for _, kc := range topN(counts, n) {
+			line(prefix+"."+kc.key, kc.count)
+		}
+	}
+	// Имена хостов (--resolve-dns) — отдельными строками, чтобы ключи рейтингов не менялись
+	hostnames := func(counts map[string]int, n int) {
+		for _, kc := range topN(counts, n) {
+			if name, ok := opts.Hostnames[kc.key]; ok {
+				line("hostname."+kc.key, name)
+			}
+		}
+	}
+
+	if opts.Stats.has(statTotal) {
+		line("total_requests", stats.TotalRequests)
+	}
+	if opts.Stats.has(statErrors) {
+		line("errors", stats.ErrorCount)
+		if !stats.FirstErrorAt.IsZero() {
+			line("first_error", stats.FirstErrorAt.Format(timestampLayout))
+			line("last_error", stats.LastErrorAt.Format(timestampLayout))
+		}
+	}
+	if opts.Stats.has(statStatusClasses) {
+		line("success_2xx", stats.SuccessCount)
+		line("redirects_3xx", stats.RedirectCount)
+		for _, key := range slices.Sorted(maps.Keys(stats.RequestsByStatus)) {
+			line("status."+statusLabel(key, opts.StatusByClass), stats.RequestsByStatus[key])
+		}
+	}
+	if opts.Stats.has(statAvgTime) {
+		line("avg_response_time_ms", fmt.Sprintf("%.2f", stats.AverageRespTime))
+		line("stddev_response_time_ms", fmt.Sprintf("%.2f", stats.StdDevRespTime))
+		for class := 1; class < len(stats.RequestsByClass); class++ {
+			if stats.RequestsByClass[class] > 0 {
+				line(fmt.Sprintf("avg_response_time_ms.%dxx", class), fmt.Sprintf("%.2f", stats.AvgRespTimeByClass[class]))
+			}
+		}
+	}
+	if opts.SlowThreshold > 0 {
+		line("slow_requests", stats.SlowCount)
+		line("slow_requests_percent", fmt.Sprintf("%.2f", percent(stats.SlowCount, stats.TotalRequests)))
+	}
+	if opts.FastThreshold > 0 {
+		line("fast_requests", stats.FastCount)
+		line("fast_requests_percent", fmt.Sprintf("%.2f", percent(stats.FastCount, stats.TotalRequests)))
+	}
+	if len(opts.SLOs) > 0 {
+		line("slos", len(stats.SLOResults))
+		for i, r := range stats.SLOResults {
+			line(fmt.Sprintf("slo.%d", i), fmt.Sprintf("%s p%g=%s target=%s requests=%d compliance=%.2f met=%t",
+				r.Target, r.Target.Percentile, formatMillis(r.Actual), formatMillis(r.Target.Latency), r.Requests, sloCompliance(r), r.Met()))
+		}
+	}
+	if opts.AnomalySigma > 0 {
+		line("latency_anomaly_threshold_ms", fmt.Sprintf("%.2f", stats.AnomalyThreshold))
+		line("latency_anomalies", stats.AnomalyCount)
+	}
+	if opts.Stats.has(statTimeSpan) && !stats.FirstTimestamp.IsZero() {
+		line("first_timestamp", stats.FirstTimestamp.Format(timestampLayout))
+		line("last_timestamp", stats.LastTimestamp.Format(timestampLayout))
+		line("span_seconds", int64(stats.LastTimestamp.Sub(stats.FirstTimestamp)/time.Second))
+	}
+	if opts.Stats.has(statPeakRate) && stats.PeakRate > 0 {
+		line("peak_requests_per_second", stats.PeakRate)
+		line("peak_second", stats.PeakSecond.Format(timestampLayout))
+	}
+	if opts.Stats.has(statHourOfDay) {
+		for hour, count := range stats.RequestsByHour {
+			line(fmt.Sprintf("requests_by_hour.%02d", hour), count)
+		}
+	}
+	if opts.GapInterval > 0 {
+		line("coverage_gaps", len(stats.CoverageGaps))
+		for i, gap := range stats.CoverageGaps {
+			line(fmt.Sprintf("coverage_gap.%d", i), gap.Start.Format(timestampLayout)+" - "+gap.End.Format(timestampLayout))
+		}
+	}
+	if opts.SpikeFactor > 0 {
+		line("spikes", len(stats.Spikes))
+		for i, spike := range stats.Spikes {
+			line(fmt.Sprintf("spike.%d", i), fmt.Sprintf("%s count=%d baseline=%.1f", spike.Start.Format(timestampLayout), spike.Count, spike.Baseline))
+		}
+	}
+	if opts.TopBy != "" {
+		_, _, values := topByRanking(stats, opts.TopBy)
+		ranking("top_by_"+strings.ReplaceAll(opts.TopBy, "-", "_"), values, 5)
+		hostnames(values, 5)
+	}
+	if opts.Stats.has(statTopIPs) && opts.TopBy == "" {
+		ranking("top_ips", stats.RequestsByIP, 5)
+		hostnames(stats.RequestsByIP, 5)
+		line("ip_gini", fmt.Sprintf("%.4f", stats.IPGini))
+	}
+	if opts.Stats.has(statTopURLs) && opts.TopBy == "" {
+		ranking("top_urls", stats.RequestsByURL, 5)
+	}
+	if opts.Stats.has(statTopEndpoints) {
+		ranking("top_endpoints", stats.RequestsByEndpoint, opts.TopEndpoints)
+	}
+	if opts.Stats.has(statMethods) {
+		ranking("methods", stats.RequestsByMethod, 0)
+	}
+	if opts.Stats.has(statCacheStatus) && len(stats.CacheStatusCounts) > 0 {
+		line("cache_hit_ratio", fmt.Sprintf("%.4f", cacheHitRatio(stats.CacheStatusCounts)))
+		ranking("cache_status", stats.CacheStatusCounts, 0)
+	}
+	if opts.GroupByParam != "" {
+		ranking("param."+opts.GroupByParam, stats.RequestsByParam, 0)
+	}
+	if stats.SkippedLines.Total > 0 {
+		line("skipped_lines", stats.SkippedLines.Total)
+		for kind, count := range stats.SkippedLines.ByKind {
+			if count > 0 {
+				line("skipped_lines."+parseErrorKind(kind).String(), count)
+			}
+		}
+	}
+	if hasResponseTimeRange(opts) {
+		line("response_time_outliers", stats.ResponseTimeOutliers)
+	}
+	if stats.URLDecodeErrors > 0 {
+		line("url_decode_errors", stats.URLDecodeErrors)
+	}
+	if opts.WorkerStats {
+		for i, count := range stats.WorkerCounts {
+			line(fmt.Sprintf("worker.%d", i), count)
+		}
+	}
+}
+
+// Отчет в виде таблиц GitHub Markdown (--format=markdown): сводка показателей и рейтинги,
+// для вставки в issue и вики. Показатели те же, что в текстовом отчете (кроме гистограммы:
+// запросы по часам выводятся таблицей); количества выводятся с разделителем разрядов,
+// символы | в значениях экранируются.
+func writeMarkdownReport(w io.Writer, stats Statistics, opts Options) {
+	var summary [][]string
+	row := func(name string, value string) {
+		summary = append(summary, []string{name, value})
+	}
+
+	if opts.Stats.has(statTotal) {
+		row("Всего запросов", formatCount(stats.TotalRequests))
+	}
+	if opts.Stats.has(statErrors) {
+		row(fmt.Sprintf("Ошибок (%s)", errorDefinition(opts)), formatCount(stats.ErrorCount))
+		if !stats.FirstErrorAt.IsZero() {
+			row("Первая ошибка", stats.FirstErrorAt.Format(timestampLayout))
+			row("Последняя ошибка", stats.LastErrorAt.Format(timestampLayout))
+		}
+	}
+	if opts.Stats.has(statStatusClasses) {
+		row("Успешных ответов (2xx)", formatCount(stats.SuccessCount))
+		row("Перенаправлений (3xx)", formatCount(stats.RedirectCount))
+	}
+	if opts.Stats.has(statAvgTime) {
+		row("Среднее время ответа", fmt.Sprintf("%.2f ms", stats.AverageRespTime))
+		row("Стандартное отклонение времени ответа", fmt.Sprintf("%.2f ms", stats.StdDevRespTime))
+	}
+	if opts.SlowThreshold > 0 {
+		row(fmt.Sprintf("Медленных запросов (дольше %v)", opts.SlowThreshold),
+			fmt.Sprintf("%s (%.1f%%)", formatCount(stats.SlowCount), percent(stats.SlowCount, stats.TotalRequests)))
+	}
+	if opts.FastThreshold > 0 {
+		row(fmt.Sprintf("Быстрых запросов (быстрее %v)", opts.FastThreshold),
+			fmt.Sprintf("%s (%.1f%%)", formatCount(stats.FastCount), percent(stats.FastCount, stats.TotalRequests)))
+	}
+	if opts.AnomalySigma > 0 {
+		row(fmt.Sprintf("Аномалии задержки (больше %.2f ms)", stats.AnomalyThreshold), formatCount(stats.AnomalyCount))
+	}
+	if opts.Stats.has(statTimeSpan) && !stats.FirstTimestamp.IsZero() {
+		row("Период", fmt.Sprintf("%s — %s (%v)", stats.FirstTimestamp.Format(timestampLayout),
+			stats.LastTimestamp.Format(timestampLayout), stats.LastTimestamp.Sub(stats.FirstTimestamp)))
+	}
+	if opts.Stats.has(statPeakRate) && stats.PeakRate > 0 {
+		row("Пиковая нагрузка за 1 с", fmt.Sprintf("%s запросов/с (%s)", formatCount(stats.PeakRate), stats.PeakSecond.Format(timestampLayout)))
+	}
+	if opts.Stats.has(statTopIPs) && opts.TopBy == "" {
+		row("Коэффициент Джини по IP", fmt.Sprintf("%.2f", stats.IPGini))
+	}
+	if opts.Stats.has(statCacheStatus) && len(stats.CacheStatusCounts) > 0 {
+		row("Доля ответов из кэша (HIT)", fmt.Sprintf("%.1f%%", cacheHitRatio(stats.CacheStatusCounts)*100))
+	}
+	if stats.SkippedLines.Total > 0 {
+		row("Пропущено строк", stats.SkippedLines.String())
+	}
+	if hasResponseTimeRange(opts) {
+		row("Отброшено по времени ответа ("+describeResponseTimeRange(opts)+")", formatCount(stats.ResponseTimeOutliers))
+	}
+	if stats.URLDecodeErrors > 0 {
+		row("Нераскодированных URL", formatCount(stats.URLDecodeErrors))
+	}
+	writeMarkdownTable(w, "Сводка", []string{"Показатель", "Значение"}, summary)
+
+	// Рейтинг: ключ и количество по убыванию
+	ranking := func(title, keyName, unit string, counts map[string]int, n int) {
+		var rows [][]string
+		for _, kc := range topN(counts, n) {
+			rows = append(rows, []string{withHostname(kc.key, opts.Hostnames), formatCount(kc.count)})
+		}
+		writeMarkdownTable(w, title, []string{keyName, unit}, rows)
+	}
+
+	if opts.Stats.has(statStatusClasses) {
+		var rows [][]string
+		for _, key := range slices.Sorted(maps.Keys(stats.RequestsByStatus)) {
+			rows = append(rows, []string{statusLabel(key, opts.StatusByClass), formatCount(stats.RequestsByStatus[key])})
+		}
+		writeMarkdownTable(w, "Ответы по статусам", []string{"Статус", "Ответов"}, rows)
+	}
+	if opts.Stats.has(statAvgTime) {
+		var rows [][]string
+		for class := 1; class < len(stats.RequestsByClass); class++ {
+			if stats.RequestsByClass[class] > 0 {
+				rows = append(rows, []string{fmt.Sprintf("%dxx", class), formatCount(stats.RequestsByClass[class]),
+					fmt.Sprintf("%.2f ms", stats.AvgRespTimeByClass[class])})
+			}
+		}
+		if stats.RequestsByClass[0] > 0 {
+			rows = append(rows, []string{"other", formatCount(stats.RequestsByClass[0]), fmt.Sprintf("%.2f ms", stats.AvgRespTimeByClass[0])})
+		}
+		writeMarkdownTable(w, "Среднее время ответа по классам статусов", []string{"Класс", "Запросов", "Среднее время"}, rows)
+	}
+	if len(opts.SLOs) > 0 {
+		var rows [][]string
+		for _, r := range stats.SLOResults {
+			rows = append(rows, []string{r.Target.String(), fmt.Sprintf("p%g", r.Target.Percentile),
+				sloActual(r), formatMillis(r.Target.Latency) + " ms", formatCount(r.Requests),
+				fmt.Sprintf("%.2f%%", sloCompliance(r)), sloStatus(r)})
+		}
+		writeMarkdownTable(w, "Соответствие SLO", []string{"Цель", "Перцентиль", "Факт", "Цель по времени", "Запросов", "Не дольше цели", "Итог"}, rows)
+	}
+	if opts.AnomalySigma > 0 {
+		var rows [][]string
+		for _, e := range stats.Anomalies {
+			rows = append(rows, markdownEntryRow(e, formatMillis(e.ResponseTime)+" ms"))
+		}
+		writeMarkdownTable(w, fmt.Sprintf("Самые медленные аномалии задержки (среднее + %g·σ)", opts.AnomalySigma),
+			markdownEntryHeaders("Время ответа"), rows)
+	}
+	if opts.Stats.has(statHourOfDay) {
+		var rows [][]string
+		for hour, count := range stats.RequestsByHour {
+			rows = append(rows, []string{fmt.Sprintf("%02d:00", hour), formatCount(count)})
+		}
+		writeMarkdownTable(w, "Запросы по часам суток", []string{"Час", "Запросов"}, rows)
+	}
+	if opts.GapInterval > 0 {
+		var rows [][]string
+		for _, gap := range stats.CoverageGaps {
+			rows = append(rows, []string{gap.Start.Format(timestampLayout), gap.End.Format(timestampLayout), gap.End.Sub(gap.Start).String()})
+		}
+		writeMarkdownTable(w, fmt.Sprintf("Пропуски в логах (интервалы по %v без запросов)", opts.GapInterval), []string{"Начало", "Конец", "Длительность"}, rows)
+	}
+	if opts.SpikeFactor > 0 {
+		var rows [][]string
+		for _, spike := range stats.Spikes {
+			rows = append(rows, []string{spike.Start.Format(timestampLayout), formatCount(spike.Count), fmt.Sprintf("%.1f", spike.Baseline)})
+		}
+		writeMarkdownTable(w, fmt.Sprintf("Всплески нагрузки (интервалы по %v, больше медианы × %g)", opts.SpikeInterval, opts.SpikeFactor), []string{"Начало", "Запросов", "Медиана"}, rows)
+	}
+	if opts.TopBy != "" {
+		title, unit, values := topByRanking(stats, opts.TopBy)
+		ranking("Топ "+title, "Ключ", unit, values, 5)
+	}
+	if opts.Stats.has(statTopIPs) && opts.TopBy == "" {
+		var rows [][]string
+		for _, kc := range topN(stats.RequestsByIP, 5) {
+			rows = append(rows, []string{withHostname(kc.key, opts.Hostnames), formatCount(kc.count), fmt.Sprintf("%.1f%%", percent(kc.count, stats.TotalRequests))})
+		}
+		writeMarkdownTable(w, "Топ IP адресов", []string{"IP", "Запросов", "Доля"}, rows)
+	}
+	if opts.Stats.has(statTopURLs) && opts.TopBy == "" {
+		// С --verbose у каждого URL — разбивка по классам статусов
+		if stats.URLStatusClasses != nil {
+			var rows [][]string
+			for _, kc := range topN(stats.RequestsByURL, 5) {
+				classes := ""
+				if counts, ok := stats.URLStatusClasses[kc.key]; ok {
+					classes = counts.String()
+				}
+				rows = append(rows, []string{kc.key, formatCount(kc.count), classes})
+			}
+			writeMarkdownTable(w, "Топ URL", []string{"URL", "Запросов", "Статусы"}, rows)
+		} else {
+			ranking("Топ URL", "URL", "Запросов", stats.RequestsByURL, 5)
+		}
+	}
+	if opts.Stats.has(statTopEndpoints) {
+		ranking("Топ эндпоинтов", "Эндпоинт", "Запросов", stats.RequestsByEndpoint, opts.TopEndpoints)
+	}
+	if opts.Stats.has(statMethods) {
+		ranking("Запросы по методам", "Метод", "Запросов", stats.RequestsByMethod, 0)
+	}
+	if opts.Stats.has(statCacheStatus) && len(stats.CacheStatusCounts) > 0 {
+		ranking("Ответы по статусу кэша", "Статус", "Ответов", stats.CacheStatusCounts, 0)
+	}
+	if opts.GroupByParam != "" {
+		ranking("Запросы по параметру "+opts.GroupByParam, "Значение", "Запросов", stats.RequestsByParam, 0)
+	}
+	if len(stats.ErrorCorrelations) > 0 {
+		correlations := slices.Clone(stats.ErrorCorrelations)
+		slices.SortStableFunc(correlations, func(a, b errorCorrelation) int {
+			return strings.Compare(a.Entry.Timestamp, b.Entry.Timestamp)
+		})
+		var rows [][]string
+		for _, c := range correlations {
+			message := c.Message
+			if message == "" {
+				message = "(нет сообщения)"
+			}
+			rows = append(rows, markdownEntryRow(c.Entry, message))
+		}
+		writeMarkdownTable(w, "Ответы 5xx и сообщения из журнала ошибок", markdownEntryHeaders("Сообщение"), rows)
+	}
+	if opts.WorkerStats {
+		var rows [][]string
+		for i, count := range stats.WorkerCounts {
+			rows = append(rows, []string{fmt.Sprint(i), formatCount(count)})
+		}
+		writeMarkdownTable(w, "Записи по воркерам", []string{"Воркер", "Записей"}, rows)
+	}
+}
+
+// Колонки таблицы записей лога: время, IP, запрос, статус и последняя колонка last
+func markdownEntryHeaders(last string) []string {
+	return []string{"Время", "IP", "Запрос", "Статус", last}
+}
+
+// Строка таблицы записей лога со значением последней колонки last
+func markdownEntryRow(e LogEntry, last string) []string {
+	return []string{e.Timestamp, e.IP, e.Method + " " + e.URL, strconv.Itoa(e.StatusCode), last}
+}
+
+// Таблица Markdown с заголовком раздела. Пустая таблица не выводится; первая колонка
+// выровнена влево, остальные (числа) — вправо.
+func writeMarkdownTable(w io.Writer, title string, headers []string, rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "### %s\n\n", escapeMarkdown(title))
+	writeRow := func(cells []string) {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = escapeMarkdown(cell)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+	}
+	writeRow(headers)
+	align := make([]string, len(headers))
+	for i := range align {
+		align[i] = "---:"
+	}
+	align[0] = ":---"
+	fmt.Fprintf(w, "| %s |\n", strings.Join(align, " | "))
+	for _, row := range rows {
+		writeRow(row)
+	}
+	fmt.Fprintln(w)
+}
+
+// Экранируем значение для ячейки таблицы Markdown: | разделяет ячейки, а перевод
+// строки закончил бы таблицу
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ", "\r", " ")
+
+func escapeMarkdown(value string) string {
+	return markdownEscaper.Replace(value)
+}
+
+// Проверяем значение --format
+func validReportFormat(format string) bool {
+	_, ok := reportWriters[format]
+	return ok
+}
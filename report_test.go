@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteCompactReport(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(opts *Options)
+		want  string
+	}{
+		{
+			name:  "по умолчанию",
+			setup: func(opts *Options) {},
+			want: `total_requests: 5
+errors: 2
+first_error: 2024-01-15 10:30:02
+last_error: 2024-01-15 10:30:02
+success_2xx: 2
+redirects_3xx: 1
+status.200: 1
+status.201: 1
+status.302: 1
+status.404: 1
+status.500: 1
+avg_response_time_ms: 280.00
+stddev_response_time_ms: 364.14
+avg_response_time_ms.2xx: 150.00
+avg_response_time_ms.3xx: 50.00
+avg_response_time_ms.4xx: 50.00
+avg_response_time_ms.5xx: 1000.00
+first_timestamp: 2024-01-15 10:30:00
+last_timestamp: 2024-01-15 10:30:03
+span_seconds: 3
+peak_requests_per_second: 2
+peak_second: 2024-01-15 10:30:02
+top_ips.10.0.0.1: 3
+top_ips.10.0.0.2: 1
+top_ips.10.0.0.3: 1
+ip_gini: 0.2667
+top_urls./api/users: 3
+top_urls./api/products: 1
+top_urls./api/users/1: 1
+top_endpoints.GET /api/users: 2
+top_endpoints.GET /api/products: 1
+top_endpoints.GET /api/users/1: 1
+top_endpoints.POST /api/users: 1
+methods.GET: 4
+methods.POST: 1
+`,
+		},
+		{
+			name: "выбранные показатели, статусы по классам",
+			setup: func(opts *Options) {
+				opts.Stats = statTotal | statStatusClasses
+				opts.StatusByClass = true
+			},
+			want: `total_requests: 5
+success_2xx: 2
+redirects_3xx: 1
+status.2xx: 2
+status.3xx: 1
+status.4xx: 1
+status.5xx: 1
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultOptions()
+			tt.setup(&opts)
+			var out bytes.Buffer
+			writeCompactReport(&out, calculateTestStats(testEntries, opts), opts)
+			if out.String() != tt.want {
+				t.Errorf("отчет:\n%s\nожидалось:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// Каждый формат из справки --format выводится своим ReportWriter
+func TestReportWriters(t *testing.T) {
+	if len(reportWriters) != len(reportFormats) {
+		t.Errorf("форматов %d, а в справке %d", len(reportWriters), len(reportFormats))
+	}
+	for _, format := range reportFormats {
+		if !validReportFormat(format) {
+			t.Errorf("для формата %s нет ReportWriter", format)
+		}
+	}
+}
+
+// В Markdown есть те же разделы, что в текстовом отчете
+func TestWriteMarkdownReportSections(t *testing.T) {
+	opts := defaultOptions()
+	opts.Verbose = true
+	opts.AnomalySigma = 1
+	stats := calculateTestStats(testEntries, opts)
+	stats.ErrorCorrelations = []errorCorrelation{
+		{Entry: testEntries[3], Message: "upstream | timeout"},
+	}
+
+	var out bytes.Buffer
+	reportWriters["markdown"].WriteReport(&out, stats, opts)
+	for _, want := range []string{
+		"### Среднее время ответа по классам статусов",
+		"| 5xx | 1 | 1000.00 ms |",
+		"### Самые медленные аномалии задержки (среднее + 1·σ)",
+		"| 2024-01-15 10:30:02 | 10.0.0.3 | GET /api/products | 500 | 1000 ms |",
+		"| URL | Запросов | Статусы |",
+		"| /api/users | 3 | 2xx:2 3xx:1 |",
+		"### Ответы 5xx и сообщения из журнала ошибок",
+		`| 2024-01-15 10:30:02 | 10.0.0.3 | GET /api/products | 500 | upstream \| timeout |`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("в отчете нет %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// --stats=cache выводит только долю ответов из кэша, и только если есть колонка cache_status
+func TestStatsCacheOnly(t *testing.T) {
+	const rows = "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10,hit\n" +
+		"2024-01-15 10:30:01,10.0.0.1,GET,/a,200,20,MISS\n" +
+		"2024-01-15 10:30:02,10.0.0.2,GET,/b,200,30,HIT\n" +
+		"2024-01-15 10:30:03,10.0.0.2,GET,/b,500,40,BYPASS\n"
+	tests := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{
+			name: "с колонкой cache_status",
+			logs: "timestamp,ip,method,url,status,response_time,cache_status\n" + rows,
+			want: "Доля ответов из кэша (HIT): 50.0%\nОтветы по статусу кэша:\nHIT: 2\nBYPASS: 1\nMISS: 1\n",
+		},
+		{
+			name: "без колонки cache_status",
+			logs: testLogsHeader + "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,10\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAnalyzeConfig()
+			if err := cfg.flags.Parse([]string{"--stats=cache"}); err != nil {
+				t.Fatal(err)
+			}
+			stats, err := runPipeline(t.Context(), strings.NewReader(tt.logs), cfg.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			writeTextReport(&out, stats, cfg.opts)
+			if out.String() != tt.want {
+				t.Errorf("отчет:\n%s\nожидалось:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
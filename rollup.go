@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Формат часа в именах файлов почасовых сводок: 2024-01-15-10.json
+const rollupHourLayout = "2006-01-02-15"
+
+// Сводка статистики за один час
+type hourSummary struct {
+	Hour            string  `json:"hour"`
+	TotalRequests   int     `json:"total_requests"`
+	ErrorCount      int     `json:"error_count"`
+	AverageRespTime float64 `json:"average_response_time_ms"`
+
+	totalRespTime float64 // сумма времени ответа в ms для расчета среднего
+	dirty         bool    // сводка изменилась с момента последней записи
+}
+
+// Почасовые сводки: каждая запись из input учитывается в сводке своего часа
+// и передается дальше без изменений. Сводки пишутся в dir/<час>.json
+// с атомарной заменой файла: при переходе к новому часу (сводка предыдущего часа),
+// раз в interval (все изменившиеся сводки) и после окончания входных данных.
+// Записи с нераспознанным временем в сводки не попадают.
+func rollupLogs(input <-chan LogEntry, dir string, interval time.Duration, isError logPredicate) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		summaries := make(map[string]*hourSummary)
+		currentHour := ""
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case logEntry, ok := <-input:
+				if !ok {
+					flushHourSummaries(dir, summaries)
+					return
+				}
+
+				if ts, err := time.Parse(timestampLayout, logEntry.Timestamp); err == nil {
+					hour := ts.Format(rollupHourLayout)
+					// Час сменился — сразу записываем сводку за предыдущий час
+					if currentHour != "" && hour != currentHour {
+						if summary := summaries[currentHour]; summary != nil && summary.dirty {
+							writeHourSummary(dir, summary)
+						}
+					}
+					currentHour = hour
+
+					summary := summaries[hour]
+					if summary == nil {
+						summary = &hourSummary{Hour: hour}
+						summaries[hour] = summary
+					}
+					summary.add(logEntry, isError)
+				}
+
+				out <- logEntry
+			case <-ticker.C:
+				flushHourSummaries(dir, summaries)
+			}
+		}
+	}()
+
+	return out
+}
+
+// Учитываем запись в сводке
+func (s *hourSummary) add(logEntry LogEntry, isError logPredicate) {
+	s.TotalRequests++
+	if isError(logEntry) {
+		s.ErrorCount++
+	}
+	s.totalRespTime += logEntry.ResponseTime
+	s.AverageRespTime = s.totalRespTime / float64(s.TotalRequests)
+	s.dirty = true
+}
+
+// Записываем все сводки, изменившиеся с момента последней записи
+func flushHourSummaries(dir string, summaries map[string]*hourSummary) {
+	for _, summary := range summaries {
+		if summary.dirty {
+			writeHourSummary(dir, summary)
+		}
+	}
+}
+
+// Записываем сводку за час в dir/<час>.json
+func writeHourSummary(dir string, summary *hourSummary) {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		log.Printf("ошибка сериализации сводки за %s: %v", summary.Hour, err)
+		return
+	}
+	path := filepath.Join(dir, summary.Hour+".json")
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		log.Printf("ошибка записи сводки %s: %v", path, err)
+		return
+	}
+	summary.dirty = false
+}
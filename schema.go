@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Поля LogEntry, которые сопоставляются колонкам CSV
+type logField int
+
+const (
+	fieldTimestamp logField = iota
+	fieldIP
+	fieldMethod
+	fieldURL
+	fieldStatus
+	fieldResponseTime
+	fieldBytes
+	fieldUserAgent
+	fieldReferer
+	fieldCacheStatus
+	numLogFields
+)
+
+// Названия колонок в заголовке CSV для каждого поля
+var columnNames = map[string]logField{
+	"timestamp":     fieldTimestamp,
+	"ip":            fieldIP,
+	"method":        fieldMethod,
+	"url":           fieldURL,
+	"status":        fieldStatus,
+	"response_time": fieldResponseTime,
+	"bytes":         fieldBytes,
+	"user_agent":    fieldUserAgent,
+	"referer":       fieldReferer,
+	"cache_status":  fieldCacheStatus,
+}
+
+// Поля, без которых запись лога не имеет смысла
+var requiredFields = []logField{fieldTimestamp, fieldIP, fieldMethod, fieldURL, fieldStatus, fieldResponseTime}
+
+// Единица измерения времени ответа во входных данных
+type timeUnit string
+
+const (
+	unitMilliseconds timeUnit = "ms"
+	unitMicroseconds timeUnit = "us"
+	unitSeconds      timeUnit = "s"
+)
+
+// Разбираем значение флага --time-unit
+func parseTimeUnit(value string) (timeUnit, error) {
+	switch unit := timeUnit(value); unit {
+	case unitMilliseconds, unitMicroseconds, unitSeconds:
+		return unit, nil
+	default:
+		return "", fmt.Errorf("неизвестная единица времени %q (допустимо: ms, us, s)", value)
+	}
+}
+
+// Переводим время ответа из единицы unit в миллисекунды (дробная часть сохраняется)
+func (unit timeUnit) toMillis(value float64) float64 {
+	switch unit {
+	case unitMicroseconds:
+		return value / 1000
+	case unitSeconds:
+		return value * 1000
+	default:
+		return value
+	}
+}
+
+// Схема CSV: количество колонок в строке и индекс колонки для каждого поля
+// (-1, если поля в схеме нет — тогда в LogEntry оно остается нулевым)
+type logSchema struct {
+	columns   int
+	index     [numLogFields]int
+	delimiter string   // разделитель колонок
+	hasHeader bool     // есть ли во входных данных строка заголовка
+	timeUnit  timeUnit // единица времени ответа во входных данных
+
+	// Допускать лишние колонки в конце строки (--allow-extra-fields): они отбрасываются
+	allowExtra bool
+
+	// Не очищать значения колонок от пробелов по краям (--no-trim). Колонки
+	// фиксированной ширины очищаются всегда: пробелы в них — выравнивание.
+	noTrim bool
+
+	// Ширины колонок в байтах для строк фиксированной ширины (nil — колонки
+	// разделяются delimiter). Значения колонок очищаются от пробелов по краям.
+	widths []int
+
+	// Формат времени во входных данных (в нотации Go). Если он отличается от
+	// timestampLayout, время при парсинге приводится к timestampLayout.
+	timestampFormat string
+}
+
+// Пустая схема на columns колонок: ни одно поле не сопоставлено
+func newSchema(columns int) logSchema {
+	schema := logSchema{
+		columns:         columns,
+		delimiter:       ",",
+		hasHeader:       true,
+		timeUnit:        unitMilliseconds,
+		timestampFormat: timestampLayout,
+	}
+	for i := range schema.index {
+		schema.index[i] = -1
+	}
+	return schema
+}
+
+// Исходная схема из 6 колонок:
+// timestamp,ip,method,url,status,response_time
+func defaultSchema() logSchema {
+	schema := newSchema(6)
+	for i, field := range requiredFields {
+		schema.index[field] = i
+	}
+	return schema
+}
+
+// Проверяем, есть ли поле в схеме
+func (s logSchema) has(field logField) bool {
+	return s.index[field] >= 0
+}
+
+// Делим строку на колонки: по разделителю (не больше чем на columns+1 частей, чтобы
+// строка с огромным числом разделителей не приводила к лишним аллокациям) или по
+// ширинам колонок. Строка фиксированной ширины может оказаться короче суммы ширин —
+// тогда колонок получится меньше; символы после последней колонки отбрасываются.
+func (s logSchema) splitFields(line string) []string {
+	if s.widths == nil {
+		return strings.SplitN(line, s.delimiter, s.columns+1)
+	}
+	fields := make([]string, 0, len(s.widths))
+	start := 0
+	for _, width := range s.widths {
+		if start >= len(line) {
+			break
+		}
+		end := min(start+width, len(line))
+		fields = append(fields, strings.TrimSpace(line[start:end]))
+		start = end
+	}
+	return fields
+}
+
+// Схема для строк фиксированной ширины (--input-format=fixed) без --schema:
+// колонки идут в порядке timestamp, ip, method, url, status, response_time,
+// за ними необязательные bytes, user_agent, referer и cache_status. Заголовка нет.
+func fixedWidthSchema(widths []int) (logSchema, error) {
+	if len(widths) < len(requiredFields) || len(widths) > int(numLogFields) {
+		return logSchema{}, fmt.Errorf("для строк фиксированной ширины нужно от %d до %d колонок, задано %d", len(requiredFields), numLogFields, len(widths))
+	}
+	schema := newSchema(len(widths))
+	for i := range widths {
+		schema.index[logField(i)] = i
+	}
+	schema.widths = widths
+	schema.hasHeader = false
+	return schema, nil
+}
+
+// Разбираем значение --field-widths: положительные целые через запятую
+func parseFieldWidths(value string) ([]int, error) {
+	var widths []int
+	for _, part := range strings.Split(value, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("неверная ширина колонки %q", part)
+		}
+		widths = append(widths, width)
+	}
+	return widths, nil
+}
+
+// Определяем схему по строке заголовка CSV.
+// Неизвестные колонки допускаются и пропускаются при разборе,
+// обязательные колонки должны присутствовать.
+func detectSchema(header string) (logSchema, error) {
+	names := strings.Split(header, ",")
+	schema := newSchema(len(names))
+
+	for i, name := range names {
+		field, ok := columnNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			continue
+		}
+		schema.index[field] = i
+	}
+
+	for _, field := range requiredFields {
+		if !schema.has(field) {
+			return logSchema{}, fmt.Errorf("в заголовке нет обязательных колонок: %q", header)
+		}
+	}
+	return schema, nil
+}
+
+// Описание схемы для --print-schema: формат строк, наличие заголовка и какая
+// колонка сопоставлена какому полю. Если заголовок есть, рядом с номером колонки
+// выводится ее название; колонки без поля отмечаются как пропускаемые.
+func (s logSchema) describe(header string) string {
+	var b strings.Builder
+	if s.widths != nil {
+		fmt.Fprintf(&b, "Схема: колонки фиксированной ширины %v", s.widths)
+	} else {
+		fmt.Fprintf(&b, "Схема: разделитель %q", s.delimiter)
+	}
+	if header != "" {
+		b.WriteString(", заголовок есть\n")
+	} else {
+		b.WriteString(", без заголовка\n")
+	}
+
+	var headerNames []string
+	if header != "" {
+		headerNames = s.splitFields(header)
+	}
+	fields := make([]string, s.columns)
+	for field := range numLogFields {
+		if s.has(field) {
+			fields[s.index[field]] = fieldName(field)
+		}
+	}
+	for i, field := range fields {
+		fmt.Fprintf(&b, "  колонка %d", i)
+		if i < len(headerNames) {
+			fmt.Fprintf(&b, " (%q)", strings.TrimSpace(headerNames[i]))
+		}
+		switch field {
+		case "":
+			b.WriteString(" → пропускается\n")
+		case fieldName(fieldTimestamp):
+			fmt.Fprintf(&b, " → %s, формат %q\n", field, s.timestampFormat)
+		case fieldName(fieldResponseTime):
+			fmt.Fprintf(&b, " → %s, единица %s\n", field, s.timeUnit)
+		default:
+			fmt.Fprintf(&b, " → %s\n", field)
+		}
+	}
+	return b.String()
+}
+
+// Похожа ли строка на заголовок CSV: в ней есть все обязательные колонки
+func isHeaderLine(line string) bool {
+	_, err := detectSchema(line)
+	return err == nil
+}
+
+// Описание схемы в JSON файле (--schema), например:
+//
+//	{
+//	  "delimiter": ";",
+//	  "header": false,
+//	  "fields": {
+//	    "timestamp":     {"index": 0, "format": "02/Jan/2006:15:04:05"},
+//	    "ip":            {"index": 1},
+//	    "method":        {"index": 2},
+//	    "url":           {"index": 3},
+//	    "status":        {"index": 4},
+//	    "response_time": {"index": 5, "format": "us"}
+//	  }
+//	}
+//
+// Имена полей совпадают с именами колонок заголовка. Формат задается только для
+// timestamp (раскладка времени Go) и response_time (единица времени: ms, us, s).
+// Если columns не указан, он равен наибольшему индексу + 1.
+type schemaConfig struct {
+	Columns   int                          `json:"columns"`
+	Delimiter string                       `json:"delimiter"`
+	Header    *bool                        `json:"header"`
+	Fields    map[string]schemaFieldConfig `json:"fields"`
+}
+
+// Описание одного поля в JSON схеме
+type schemaFieldConfig struct {
+	Index  int    `json:"index"`
+	Format string `json:"format"`
+}
+
+// Загружаем схему из JSON файла и проверяем ее: все обязательные поля
+// сопоставлены, индексы не выходят за пределы количества колонок. Единица времени
+// ответа — unit (--time-unit), если в схеме для response_time не задан формат.
+func loadSchema(path string, unit timeUnit) (logSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return logSchema{}, err
+	}
+
+	var config schemaConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return logSchema{}, fmt.Errorf("ошибка разбора схемы %s: %w", path, err)
+	}
+
+	columns := config.Columns
+	if columns == 0 {
+		for _, field := range config.Fields {
+			columns = max(columns, field.Index+1)
+		}
+	}
+	schema := newSchema(columns)
+	if config.Delimiter != "" {
+		schema.delimiter = config.Delimiter
+	}
+	if config.Header != nil {
+		schema.hasHeader = *config.Header
+	}
+	schema.timeUnit = unit
+
+	for name, fieldConfig := range config.Fields {
+		field, ok := columnNames[name]
+		if !ok {
+			return logSchema{}, fmt.Errorf("неизвестное поле в схеме: %q", name)
+		}
+		if fieldConfig.Index < 0 || fieldConfig.Index >= columns {
+			return logSchema{}, fmt.Errorf("индекс поля %q вне диапазона 0..%d: %d", name, columns-1, fieldConfig.Index)
+		}
+		schema.index[field] = fieldConfig.Index
+
+		if fieldConfig.Format == "" {
+			continue
+		}
+		switch field {
+		case fieldTimestamp:
+			schema.timestampFormat = fieldConfig.Format
+		case fieldResponseTime:
+			if schema.timeUnit, err = parseTimeUnit(fieldConfig.Format); err != nil {
+				return logSchema{}, err
+			}
+		default:
+			return logSchema{}, fmt.Errorf("формат задается только для timestamp и response_time, а не для %q", name)
+		}
+	}
+
+	for _, field := range requiredFields {
+		if !schema.has(field) {
+			return logSchema{}, fmt.Errorf("в схеме не сопоставлено обязательное поле %q", fieldName(field))
+		}
+	}
+	return schema, nil
+}
+
+// Имя колонки для поля
+func fieldName(field logField) string {
+	for name, f := range columnNames {
+		if f == field {
+			return name
+		}
+	}
+	return fmt.Sprintf("field%d", field)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTimeUnitToMillis(t *testing.T) {
+	tests := []struct {
+		unit  string
+		value float64
+		want  float64
+	}{
+		{"ms", 150, 150},
+		{"ms", 0.5, 0.5},
+		{"us", 427, 0.427},
+		{"us", 1500000, 1500},
+		{"s", 1.5, 1500},
+		{"s", 0.002, 2},
+	}
+	for _, tt := range tests {
+		unit, err := parseTimeUnit(tt.unit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := unit.toMillis(tt.value); got != tt.want {
+			t.Errorf("%g %s = %g ms, ожидалось %g", tt.value, tt.unit, got, tt.want)
+		}
+	}
+	if _, err := parseTimeUnit("ns"); err == nil {
+		t.Error("неизвестная единица ns принята")
+	}
+}
+
+// --time-unit действует и для схемы из --schema, если в ней не задан формат response_time
+func TestTimeUnitWithSchema(t *testing.T) {
+	dir := t.TempDir()
+	fields := `"timestamp": {"index": 0}, "ip": {"index": 1}, "method": {"index": 2}, "url": {"index": 3}, "status": {"index": 4}`
+	tests := []struct {
+		name         string
+		responseTime string
+		want         float64
+	}{
+		{"без формата", `"response_time": {"index": 5}`, 2000},
+		{"формат схемы важнее", `"response_time": {"index": 5, "format": "us"}`, 0.002},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "schema.json")
+			config := `{"fields": {` + fields + `, ` + tt.responseTime + `}}`
+			if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			schema, err := loadSchema(path, unitSeconds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logEntry, err := parseLogLine("2024-01-15 10:30:00,10.0.0.1,GET,/a,200,2", 1, schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logEntry.ResponseTime != tt.want {
+				t.Errorf("ResponseTime = %g, ожидалось %g", logEntry.ResponseTime, tt.want)
+			}
+		})
+	}
+}
+
+// Время ответа в секундах во входных данных: вся статистика в ms
+func TestTimeUnitPipeline(t *testing.T) {
+	opts := defaultOptions()
+	opts.TimeUnit = unitSeconds
+	logs := testLogsHeader + "2024-01-15 10:30:00,10.0.0.1,GET,/a,200,0.25\n2024-01-15 10:30:01,10.0.0.1,GET,/a,200,0.75\n"
+	stats, err := runPipeline(t.Context(), strings.NewReader(logs), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.AverageRespTime != 500 {
+		t.Errorf("AverageRespTime = %g ms, ожидалось 500", stats.AverageRespTime)
+	}
+}
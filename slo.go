@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Цель SLO (--slo-config): для запросов, путь которых соответствует шаблону URL
+// (и метод, если задан), Percentile-й перцентиль времени ответа не больше Latency
+type sloTarget struct {
+	URL        string  // шаблон пути в синтаксисе path.Match: "/api/checkout", "/api/users/*"
+	Method     string  // пусто — любой метод
+	Latency    float64 // целевое время ответа в ms
+	Percentile float64 // перцентиль, например 99 или 99.9
+}
+
+// Описание SLO в YAML файле:
+//
+//	slos:
+//	  - url: /api/checkout
+//	    method: POST
+//	    latency: 200ms
+//	    percentile: 99
+type sloConfig struct {
+	SLOs []sloTargetConfig `yaml:"slos"`
+}
+
+// Описание одной цели в YAML файле
+type sloTargetConfig struct {
+	URL        string  `yaml:"url"`
+	Method     string  `yaml:"method"`
+	Latency    string  `yaml:"latency"`
+	Percentile float64 `yaml:"percentile"`
+}
+
+// Загружаем цели SLO из YAML файла и проверяем их: шаблон URL корректен, время ответа
+// положительно, перцентиль в диапазоне (0, 100]
+func loadSLOConfig(file string) ([]sloTarget, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config sloConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("ошибка разбора %s: %w", file, err)
+	}
+	if len(config.SLOs) == 0 {
+		return nil, fmt.Errorf("в %s не задано ни одной цели (список slos)", file)
+	}
+
+	targets := make([]sloTarget, 0, len(config.SLOs))
+	for i, c := range config.SLOs {
+		target, err := c.target()
+		if err != nil {
+			return nil, fmt.Errorf("цель %d в %s: %w", i+1, file, err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// Проверяем описание цели и переводим его в sloTarget
+func (c sloTargetConfig) target() (sloTarget, error) {
+	if c.URL == "" {
+		return sloTarget{}, fmt.Errorf("не задан url")
+	}
+	if _, err := path.Match(c.URL, ""); err != nil {
+		return sloTarget{}, fmt.Errorf("неверный шаблон url %q: %w", c.URL, err)
+	}
+	latency, err := time.ParseDuration(c.Latency)
+	if err != nil {
+		return sloTarget{}, fmt.Errorf("неверное время ответа latency %q: %w", c.Latency, err)
+	}
+	if latency <= 0 {
+		return sloTarget{}, fmt.Errorf("время ответа latency должно быть положительным: %v", latency)
+	}
+	if c.Percentile <= 0 || c.Percentile > 100 {
+		return sloTarget{}, fmt.Errorf("перцентиль должен быть в диапазоне (0, 100]: %g", c.Percentile)
+	}
+	return sloTarget{
+		URL:        c.URL,
+		Method:     strings.ToUpper(c.Method),
+		Latency:    durationMillis(latency),
+		Percentile: c.Percentile,
+	}, nil
+}
+
+// Относится ли запись к цели: путь URL (без query string и завершающего "/", как
+// у группировки URL) соответствует шаблону, и метод совпадает, если он задан
+func (t sloTarget) matches(logEntry LogEntry) bool {
+	if t.Method != "" && !strings.EqualFold(logEntry.Method, t.Method) {
+		return false
+	}
+	matched, _ := path.Match(t.URL, urlKey(logEntry.URL, 0))
+	return matched
+}
+
+// Название цели в отчете: "POST /api/checkout" или "/api/checkout"
+func (t sloTarget) String() string {
+	if t.Method == "" {
+		return t.URL
+	}
+	return t.Method + " " + t.URL
+}
+
+// Результат проверки цели SLO
+type sloResult struct {
+	Target       sloTarget
+	Requests     int     // запросов, относящихся к цели
+	Actual       float64 // фактический перцентиль времени ответа в ms (0, если запросов нет)
+	WithinTarget int     // запросов не дольше Latency
+}
+
+// Выполнена ли цель: запросов нет или перцентиль не больше целевого времени
+func (r sloResult) Met() bool {
+	return r.Actual <= r.Target.Latency
+}
+
+// Проверяем цель по количеству запросов с каждым (округленным) временем ответа и
+// количеству запросов не дольше цели
+func evaluateSLO(target sloTarget, respTimeCounts map[float64]int, withinTarget int) sloResult {
+	result := sloResult{Target: target, WithinTarget: withinTarget}
+	for _, count := range respTimeCounts {
+		result.Requests += count
+	}
+	result.Actual = percentileOfCounts(respTimeCounts, result.Requests, target.Percentile)
+	return result
+}
+
+// Перцентиль p (0 < p ≤ 100) значений, заданных количествами, методом ближайшего ранга:
+// наименьшее значение, не меньше которого p% из total значений (0, если значений нет)
+func percentileOfCounts(counts map[float64]int, total int, p float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	rank := max(int(math.Ceil(p/100*float64(total))), 1)
+	seen := 0
+	for _, value := range slices.Sorted(maps.Keys(counts)) {
+		seen += counts[value]
+		if seen >= rank {
+			return value
+		}
+	}
+	return 0
+}
+
+// Вывод соответствия целям SLO: фактический перцентиль против целевого
+func printSLOCompliance(w io.Writer, results []sloResult) {
+	met, empty := 0, 0
+	for _, r := range results {
+		switch {
+		case r.Requests == 0:
+			empty++
+		case r.Met():
+			met++
+		}
+	}
+	fmt.Fprintf(w, "Соответствие SLO: выполнено %d из %d", met, len(results)-empty)
+	if empty > 0 {
+		fmt.Fprintf(w, " (без запросов: %d)", empty)
+	}
+	fmt.Fprintln(w)
+	for _, r := range results {
+		if r.Requests == 0 {
+			fmt.Fprintf(w, "  %s: запросов нет (цель p%g ≤ %s ms)\n", r.Target, r.Target.Percentile, formatMillis(r.Target.Latency))
+			continue
+		}
+		fmt.Fprintf(w, "  %s: p%g = %s ms (цель %s ms), запросов %s, не дольше цели %.2f%% — %s\n",
+			r.Target, r.Target.Percentile, formatMillis(r.Actual), formatMillis(r.Target.Latency),
+			formatCount(r.Requests), sloCompliance(r), sloStatus(r))
+	}
+}
+
+// Доля запросов цели, уложившихся в целевое время, в процентах (100, если запросов нет)
+func sloCompliance(r sloResult) float64 {
+	if r.Requests == 0 {
+		return 100
+	}
+	return percent(r.WithinTarget, r.Requests)
+}
+
+// Фактический перцентиль для таблицы отчета ("—", если запросов нет)
+func sloActual(r sloResult) string {
+	if r.Requests == 0 {
+		return "—"
+	}
+	return formatMillis(r.Actual) + " ms"
+}
+
+// Итог проверки цели для отчета
+func sloStatus(r sloResult) string {
+	if r.Requests == 0 {
+		return "нет запросов"
+	}
+	if r.Met() {
+		return "выполнено"
+	}
+	return "нарушено"
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+)
+
+// Префикс имени входа для чтения из Unix сокета: unix://<путь>
+const socketScheme = "unix://"
+
+// Проверяем, является ли имя входа Unix сокетом
+func isSocketInput(name string) bool {
+	return strings.HasPrefix(name, socketScheme)
+}
+
+// Открываем Unix сокет как поток строк лога. По умолчанию подключаемся к сокету,
+// который слушает агрегатор; с opts.SocketListen сами создаем сокет и ждем одно
+// подключение. Поток заканчивается, когда отправитель закрывает соединение.
+// Отмена контекста закрывает соединение (и ожидание подключения) и завершает
+// поток как обычный конец файла, чтобы pipeline досчитал статистику.
+func openSocket(ctx context.Context, path string, opts InputOptions) (io.ReadCloser, error) {
+	if !opts.SocketListen {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "unix", path)
+		if err != nil {
+			return nil, err
+		}
+		return newSocketReader(ctx, conn), nil
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Файл сокета удаляется при закрытии слушателя
+	defer listener.Close()
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return newSocketReader(ctx, conn), nil
+}
+
+// Чтение из соединения, которое закрывается при отмене контекста
+type socketReader struct {
+	ctx  context.Context
+	conn net.Conn
+	stop func() bool
+}
+
+func newSocketReader(ctx context.Context, conn net.Conn) *socketReader {
+	return &socketReader{
+		ctx:  ctx,
+		conn: conn,
+		stop: context.AfterFunc(ctx, func() { conn.Close() }),
+	}
+}
+
+func (s *socketReader) Read(p []byte) (int, error) {
+	n, err := s.conn.Read(p)
+	// Ошибка чтения из-за закрытия по отмене контекста — штатное завершение
+	if err != nil && s.ctx.Err() != nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (s *socketReader) Close() error {
+	s.stop()
+	return s.conn.Close()
+}
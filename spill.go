@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"log"
+	"os"
+)
+
+// Очередь с выгрузкой на диск для ветви после tee (--tee-spill): записи из input
+// принимаются без ожидания потребителя. В памяти держится не больше memLimit записей,
+// остальные дописываются во временный файл в dir и читаются обратно по мере того,
+// как потребитель освобождает очередь. Порядок записей сохраняется. Файл создается
+// при первой выгрузке и удаляется после закрытия выходного канала.
+func spillQueue(input <-chan LogEntry, memLimit int, dir string) <-chan LogEntry {
+	out := make(chan LogEntry)
+	memLimit = max(memLimit, 1)
+
+	go func() {
+		defer close(out)
+
+		var (
+			queue    []LogEntry // записи в памяти, первая — следующая к выдаче
+			file     *os.File   // файл для записи
+			reader   *os.File   // тот же файл для чтения с начала
+			writer   *bufio.Writer
+			encoder  *gob.Encoder
+			decoder  *gob.Decoder
+			spilled  int // сколько записей выгружено в файл
+			restored int // сколько из них прочитано обратно
+		)
+		defer func() {
+			if file != nil {
+				reader.Close()
+				file.Close()
+				os.Remove(file.Name())
+			}
+		}()
+
+		// Выгружаем запись в конец файла
+		spill := func(logEntry LogEntry) {
+			if file == nil {
+				var err error
+				file, err = os.CreateTemp(dir, "log-processor-spill-*")
+				if err != nil {
+					log.Fatalf("ошибка создания файла для выгрузки tee: %v", err)
+				}
+				writer = bufio.NewWriter(file)
+				encoder = gob.NewEncoder(writer)
+				reader, err = os.Open(file.Name())
+				if err != nil {
+					log.Fatalf("ошибка открытия файла для выгрузки tee: %v", err)
+				}
+				decoder = gob.NewDecoder(bufio.NewReader(reader))
+			}
+			if err := encoder.Encode(logEntry); err != nil {
+				log.Fatalf("ошибка записи в файл для выгрузки tee: %v", err)
+			}
+			spilled++
+		}
+
+		// Дочитываем выгруженные записи в память, пока есть место
+		restore := func() {
+			if restored < spilled {
+				if err := writer.Flush(); err != nil {
+					log.Fatalf("ошибка записи в файл для выгрузки tee: %v", err)
+				}
+			}
+			for restored < spilled && len(queue) < memLimit {
+				var logEntry LogEntry
+				if err := decoder.Decode(&logEntry); err != nil {
+					log.Fatalf("ошибка чтения файла для выгрузки tee: %v", err)
+				}
+				queue = append(queue, logEntry)
+				restored++
+			}
+		}
+
+		for input != nil || len(queue) > 0 {
+			// Отправляем, только если есть что отправить (отправка в nil канал не выбирается)
+			var send chan<- LogEntry
+			var next LogEntry
+			if len(queue) > 0 {
+				send, next = out, queue[0]
+			}
+
+			select {
+			case logEntry, ok := <-input:
+				if !ok {
+					input = nil
+					continue
+				}
+				// Пока в файле есть непрочитанные записи, новые пишутся за ними
+				if restored == spilled && len(queue) < memLimit {
+					queue = append(queue, logEntry)
+				} else {
+					spill(logEntry)
+				}
+			case send <- next:
+				queue = queue[1:]
+				restore()
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Записи, не поместившиеся в память, выгружаются на диск и возвращаются в том же
+// порядке без потерь; временный файл удаляется после закрытия выходного канала
+func TestSpillQueueRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	entries := make([]LogEntry, 1000)
+	for i := range entries {
+		entries[i] = LogEntry{
+			Timestamp:    "2024-01-15 10:30:00",
+			IP:           fmt.Sprintf("10.0.0.%d", i%256),
+			Method:       "GET",
+			URL:          fmt.Sprintf("/item/%d", i),
+			StatusCode:   200 + i%5,
+			ResponseTime: float64(i) / 4,
+			Bytes:        i * 10,
+			UserAgent:    "curl/8.0",
+			CacheStatus:  "HIT",
+		}
+	}
+
+	// Вход пишется целиком до того, как потребитель начнет читать: все, что
+	// не поместилось в memLimit, попадает в файл
+	input := make(chan LogEntry)
+	out := spillQueue(input, 10, dir)
+	for _, logEntry := range entries {
+		input <- logEntry
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("файлов выгрузки %d, ожидался 1", len(files))
+	}
+	close(input)
+
+	got := collectEntries(out)
+	if !reflect.DeepEqual(got, entries) {
+		t.Fatalf("после выгрузки получено %d записей, ожидалось %d (или порядок/значения отличаются)", len(got), len(entries))
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("после закрытия остались файлы выгрузки: %v", files)
+	}
+}
+
+// С --tee-spill отчет и выгруженные записи (без учета порядка) те же, что без выгрузки, а файлы выгрузки
+// удаляются после завершения pipeline
+func TestTeeSpillPipeline(t *testing.T) {
+	logs := generateTestLogs(5000)
+	dir := t.TempDir()
+
+	run := func(spill bool) (report string, dumped []string) {
+		var dump bytes.Buffer
+		opts := defaultOptions()
+		opts.TeeBufferSize = 1
+		opts.TeeSpill = spill
+		opts.TeeSpillDir = dir
+		opts.Dump = &dump
+		stats, err := runPipeline(t.Context(), strings.NewReader(logs), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		writeCompactReport(&out, stats, opts)
+		dumped = strings.Split(strings.TrimSpace(dump.String()), "\n")
+		slices.Sort(dumped)
+		return out.String(), dumped
+	}
+
+	wantReport, wantDumped := run(false)
+	report, dumped := run(true)
+	if report != wantReport {
+		t.Errorf("отчет с --tee-spill отличается:\n%s\nожидалось:\n%s", report, wantReport)
+	}
+	if !slices.Equal(dumped, wantDumped) {
+		t.Errorf("выгружено %d записей с --tee-spill, ожидалось %d (или записи отличаются)", len(dumped), len(wantDumped))
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("после завершения pipeline остались файлы выгрузки: %v", files)
+	}
+}
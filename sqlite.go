@@ -0,0 +1,131 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	_ "modernc.org/sqlite" // драйвер "sqlite" без cgo
+)
+
+// Таблица logs для --sqlite: колонка на каждое поле LogEntry. Если таблица уже есть,
+// записи добавляются к ней, поэтому несколько запусков можно загрузить в одну базу.
+const sqliteCreateTable = `CREATE TABLE IF NOT EXISTS logs (
+	timestamp        TEXT,
+	ip               TEXT,
+	method           TEXT,
+	url              TEXT,
+	status           INTEGER,
+	response_time_ms REAL,
+	bytes            INTEGER,
+	user_agent       TEXT,
+	referer          TEXT,
+	cache_status     TEXT
+)`
+
+const sqliteInsert = `INSERT INTO logs (timestamp, ip, method, url, status, response_time_ms, bytes, user_agent, referer, cache_status)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Открываем (или создаем) базу SQLite и таблицу logs
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Пишет одна стадия pipeline; одно соединение исключает блокировки внутри процесса
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteCreateTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка создания таблицы logs: %w", err)
+	}
+	return db, nil
+}
+
+// Загрузка записей в таблицу logs: каждая запись из input добавляется подготовленным
+// запросом и передается дальше без изменений. Записи вставляются пачками по batchSize
+// в одной транзакции: транзакция на каждую запись была бы в сотни раз медленнее.
+// Последняя неполная пачка фиксируется после окончания входных данных. При ошибке
+// базы загрузка прекращается с сообщением в лог, а записи по-прежнему идут дальше.
+func sqliteLogs(input <-chan LogEntry, db *sql.DB, batchSize int) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		batch := &sqliteBatch{db: db}
+		failed := false
+		for logEntry := range input {
+			if !failed {
+				if err := batch.insert(logEntry); err != nil {
+					log.Printf("ошибка записи в SQLite, загрузка остановлена: %v", err)
+					batch.rollback()
+					failed = true
+				} else if batch.size >= batchSize {
+					if err := batch.commit(); err != nil {
+						log.Printf("ошибка записи в SQLite, загрузка остановлена: %v", err)
+						failed = true
+					}
+				}
+			}
+			out <- logEntry
+		}
+		if !failed {
+			if err := batch.commit(); err != nil {
+				log.Printf("ошибка записи в SQLite: %v", err)
+			}
+		}
+	}()
+
+	return out
+}
+
+// Текущая пачка вставок: открытая транзакция и подготовленный в ней запрос
+type sqliteBatch struct {
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+	size int
+}
+
+// Добавляем запись в пачку, при необходимости начиная новую транзакцию
+func (b *sqliteBatch) insert(e LogEntry) error {
+	if b.tx == nil {
+		tx, err := b.db.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare(sqliteInsert)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		b.tx, b.stmt = tx, stmt
+	}
+	_, err := b.stmt.Exec(e.Timestamp, e.IP, e.Method, e.URL, e.StatusCode, e.ResponseTime,
+		e.Bytes, e.UserAgent, e.Referer, e.CacheStatus)
+	if err == nil {
+		b.size++
+	}
+	return err
+}
+
+// Фиксируем пачку (пустая пачка — ничего не делаем)
+func (b *sqliteBatch) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+	b.stmt.Close()
+	err := b.tx.Commit()
+	b.tx, b.stmt, b.size = nil, nil, 0
+	return err
+}
+
+// Отменяем незафиксированную пачку
+func (b *sqliteBatch) rollback() {
+	if b.tx == nil {
+		return
+	}
+	b.stmt.Close()
+	b.tx.Rollback()
+	b.tx, b.stmt, b.size = nil, nil, 0
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+)
+
+// Загружаем накопленную статистику из файла состояния (--state).
+// Если файла еще нет, возвращается пустая статистика: первый запуск начинает накопление.
+func loadState(path string) (Statistics, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Statistics{}, nil
+	}
+	if err != nil {
+		return Statistics{}, err
+	}
+	var state Statistics
+	if err := json.Unmarshal(data, &state); err != nil {
+		return Statistics{}, err
+	}
+	return state, nil
+}
+
+// Сохраняем статистику в JSON файл (--state, --save-stats). Показатели, которые имеют
+// смысл только для одного запуска (образцы записей, аномалии, воркеры, сопоставление
+// с журналом ошибок, соответствие SLO), не сохраняются. Файл заменяется атомарно.
+func saveStatistics(path string, stats Statistics) error {
+	stats.Sample = logSample{}
+	stats.WorkerCounts = nil
+	stats.AnomalyThreshold, stats.AnomalyCount, stats.Anomalies = 0, 0, nil
+	stats.ErrorCorrelations = nil
+	stats.SLOResults = nil
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// Объединяем накопленную статистику total со статистикой нового запуска run.
+// Счетчики складываются, средние пересчитываются с весами по количеству запросов,
+// стандартное отклонение — по формуле объединения дисперсий. Пиковая нагрузка —
+// наибольшая из двух (точна, если запуски не пересекаются по времени).
+// Показатели одного запуска (образцы, аномалии, воркеры, соответствие SLO) берутся из run.
+func mergeStatistics(total, run Statistics) Statistics {
+	merged := run
+	n1, n2 := float64(total.TotalRequests), float64(run.TotalRequests)
+	n := n1 + n2
+
+	merged.TotalRequests = total.TotalRequests + run.TotalRequests
+	merged.ErrorCount = total.ErrorCount + run.ErrorCount
+	merged.SuccessCount = total.SuccessCount + run.SuccessCount
+	merged.RedirectCount = total.RedirectCount + run.RedirectCount
+	merged.SlowCount = total.SlowCount + run.SlowCount
+	merged.FastCount = total.FastCount + run.FastCount
+	merged.URLDecodeErrors = total.URLDecodeErrors + run.URLDecodeErrors
+	merged.ResponseTimeOutliers = total.ResponseTimeOutliers + run.ResponseTimeOutliers
+	merged.SkippedLines.Total = total.SkippedLines.Total + run.SkippedLines.Total
+	for kind := range merged.SkippedLines.ByKind {
+		merged.SkippedLines.ByKind[kind] = total.SkippedLines.ByKind[kind] + run.SkippedLines.ByKind[kind]
+	}
+
+	if n > 0 {
+		merged.AverageRespTime = (total.AverageRespTime*n1 + run.AverageRespTime*n2) / n
+		delta := run.AverageRespTime - total.AverageRespTime
+		m2 := total.StdDevRespTime*total.StdDevRespTime*n1 + run.StdDevRespTime*run.StdDevRespTime*n2 + delta*delta*n1*n2/n
+		merged.StdDevRespTime = math.Sqrt(m2 / n)
+	}
+	for class := range merged.RequestsByClass {
+		c1, c2 := total.RequestsByClass[class], run.RequestsByClass[class]
+		merged.RequestsByClass[class] = c1 + c2
+		if c1+c2 > 0 {
+			merged.AvgRespTimeByClass[class] = (total.AvgRespTimeByClass[class]*float64(c1) + run.AvgRespTimeByClass[class]*float64(c2)) / float64(c1+c2)
+		}
+	}
+	for hour := range merged.RequestsByHour {
+		merged.RequestsByHour[hour] = total.RequestsByHour[hour] + run.RequestsByHour[hour]
+	}
+	for day := range merged.RequestsByWeekdayHour {
+		for hour := range merged.RequestsByWeekdayHour[day] {
+			merged.RequestsByWeekdayHour[day][hour] = total.RequestsByWeekdayHour[day][hour] + run.RequestsByWeekdayHour[day][hour]
+		}
+	}
+
+	merged.RequestsByStatus = sumCounts(total.RequestsByStatus, run.RequestsByStatus)
+	merged.RequestsByIP = sumCounts(total.RequestsByIP, run.RequestsByIP)
+	merged.RequestsByMethod = sumCounts(total.RequestsByMethod, run.RequestsByMethod)
+	merged.RequestsByURL = sumCounts(total.RequestsByURL, run.RequestsByURL)
+	merged.RequestsByEndpoint = sumCounts(total.RequestsByEndpoint, run.RequestsByEndpoint)
+	merged.RequestsByParam = sumCounts(total.RequestsByParam, run.RequestsByParam)
+	merged.ErrorsByURL = sumCounts(total.ErrorsByURL, run.ErrorsByURL)
+	merged.BytesByIP = sumCounts(total.BytesByIP, run.BytesByIP)
+	merged.RespTimeByURL = sumCounts(total.RespTimeByURL, run.RespTimeByURL)
+	merged.CacheStatusCounts = sumCounts(total.CacheStatusCounts, run.CacheStatusCounts)
+	merged.RequestsByReferer = sumCounts(total.RequestsByReferer, run.RequestsByReferer)
+	merged.RequestsByUA = sumCounts(total.RequestsByUA, run.RequestsByUA)
+	if merged.RequestsByIP != nil {
+		merged.IPGini = giniCoefficient(merged.RequestsByIP)
+	}
+
+	if total.URLStatusClasses != nil || run.URLStatusClasses != nil {
+		merged.URLStatusClasses = make(map[string]*statusClassCounts)
+		for _, src := range []map[string]*statusClassCounts{total.URLStatusClasses, run.URLStatusClasses} {
+			for key, counts := range src {
+				existing := merged.URLStatusClasses[key]
+				if existing == nil {
+					existing = &statusClassCounts{}
+					merged.URLStatusClasses[key] = existing
+				}
+				for class := range counts {
+					existing[class] += counts[class]
+				}
+			}
+		}
+	}
+
+	if !total.FirstTimestamp.IsZero() && (merged.FirstTimestamp.IsZero() || total.FirstTimestamp.Before(merged.FirstTimestamp)) {
+		merged.FirstTimestamp = total.FirstTimestamp
+	}
+	if total.LastTimestamp.After(merged.LastTimestamp) {
+		merged.LastTimestamp = total.LastTimestamp
+	}
+	if !total.FirstErrorAt.IsZero() && (merged.FirstErrorAt.IsZero() || total.FirstErrorAt.Before(merged.FirstErrorAt)) {
+		merged.FirstErrorAt = total.FirstErrorAt
+	}
+	if total.LastErrorAt.After(merged.LastErrorAt) {
+		merged.LastErrorAt = total.LastErrorAt
+	}
+	if total.PeakRate > merged.PeakRate {
+		merged.PeakRate, merged.PeakSecond = total.PeakRate, total.PeakSecond
+	}
+	return merged
+}
+
+// Сумма счетчиков двух карт в новой карте (nil, если показатель не считался ни разу)
+func sumCounts[K comparable, V int | float64](a, b map[K]V) map[K]V {
+	if a == nil && b == nil {
+		return nil
+	}
+	sum := make(map[K]V, max(len(a), len(b)))
+	for _, src := range []map[K]V{a, b} {
+		for key, count := range src {
+			sum[key] += count
+		}
+	}
+	return sum
+}
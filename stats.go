@@ -0,0 +1,634 @@
+package main
+
+import (
+	"cmp"
+	"hash/fnv"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Накопитель статистики: учитывает записи по одной, умеет объединяться
+// с другими накопителями и в конце выдает итоговую Statistics.
+// Вычисляются только показатели, выбранные в opts.Stats; карты невыбранных
+// показателей остаются nil. Общее количество запросов считается всегда.
+type statsAccumulator struct {
+	opts    Options
+	stats   Statistics
+	isError logPredicate // что считается ошибкой (--error-codes или порог ErrorStatus)
+	// Сумма времени ответа в ms для расчета среднего. float64 хранит дробные
+	// миллисекунды, а целые суммы складывает точно до 2^53 ms (~285 тыс. лет)
+	totalRespTime float64
+
+	// Среднее и дисперсия времени ответа (онлайн-алгоритм Уэлфорда)
+	respTimeVariance welford
+
+	// Для --anomaly-sigma: количество записей с каждым временем ответа, округленным
+	// respTimeBucket (порог известен только в конце, по нему и считается количество
+	// аномалий), и самые медленные записи
+	respTimeCounts map[float64]int
+	slowest        []LogEntry
+
+	// Сумма времени ответа по классам статусов (индексы как у statusClassCounts)
+	classRespTime [6]float64
+
+	// Ответы 5xx для сопоставления с журналом ошибок (только с opts.ErrorLog)
+	serverErrors []LogEntry
+
+	// Количество запросов в каждую секунду (время в логах с точностью до секунды)
+	requestsPerSecond map[int64]int
+
+	// Количество запросов в каждом интервале --gap-interval (ключ — номер интервала от начала эпохи)
+	requestsPerGapBucket map[int64]int
+
+	// Количество запросов в каждом интервале --spike-interval (ключ как у requestsPerGapBucket)
+	requestsPerSpikeBucket map[int64]int
+
+	// Для --slo-config: количество запросов с каждым временем ответа (округленным
+	// respTimeBucket) по каждой цели (индексы как у opts.SLOs); перцентиль считается в конце.
+	// Запросы не дольше цели считаются по точному времени, без округления
+	sloRespTimeCounts []map[float64]int
+	sloWithinTarget   []int
+}
+
+// Создаем пустой накопитель для настроек opts
+func newStatsAccumulator(opts Options) *statsAccumulator {
+	acc := &statsAccumulator{opts: opts, isError: errorFilter(opts)}
+	if opts.Stats.has(statTopIPs) || opts.TopBy == "ip-requests" {
+		acc.stats.RequestsByIP = make(map[string]int)
+	}
+	if opts.Stats.has(statMethods) {
+		acc.stats.RequestsByMethod = make(map[string]int)
+	}
+	if opts.Stats.has(statTopURLs) || opts.TopBy == "url-requests" || opts.TopBy == "url-latency" {
+		acc.stats.RequestsByURL = make(map[string]int)
+	}
+	switch opts.TopBy {
+	case "url-errors":
+		acc.stats.ErrorsByURL = make(map[string]int)
+	case "ip-bytes":
+		acc.stats.BytesByIP = make(map[string]int)
+	case "url-latency":
+		acc.stats.RespTimeByURL = make(map[string]float64)
+	case "referer":
+		acc.stats.RequestsByReferer = make(map[string]int)
+	case "user-agent":
+		acc.stats.RequestsByUA = make(map[string]int)
+	}
+	if opts.Stats.has(statTopEndpoints) {
+		acc.stats.RequestsByEndpoint = make(map[string]int)
+	}
+	if opts.GroupByParam != "" {
+		acc.stats.RequestsByParam = make(map[string]int)
+	}
+	if opts.Stats.has(statTopURLs) && opts.Verbose {
+		acc.stats.URLStatusClasses = make(map[string]*statusClassCounts)
+	}
+	if opts.Stats.has(statStatusClasses) {
+		acc.stats.RequestsByStatus = make(map[int]int)
+	}
+	if opts.AnomalySigma > 0 {
+		acc.respTimeCounts = make(map[float64]int)
+	}
+	if opts.Stats.has(statPeakRate) {
+		acc.requestsPerSecond = make(map[int64]int)
+	}
+	if opts.Stats.has(statCacheStatus) {
+		acc.stats.CacheStatusCounts = make(map[string]int)
+	}
+	if opts.GapInterval > 0 {
+		acc.requestsPerGapBucket = make(map[int64]int)
+	}
+	if opts.SpikeFactor > 0 {
+		acc.requestsPerSpikeBucket = make(map[int64]int)
+	}
+	for range opts.SLOs {
+		acc.sloRespTimeCounts = append(acc.sloRespTimeCounts, make(map[float64]int))
+	}
+	acc.sloWithinTarget = make([]int, len(opts.SLOs))
+	return acc
+}
+
+// Учитываем одну запись лога
+func (acc *statsAccumulator) Add(logEntry LogEntry) {
+	opts := acc.opts
+	stats := &acc.stats
+
+	stats.TotalRequests++
+	if opts.Stats.has(statErrors) && acc.isError(logEntry) {
+		stats.ErrorCount++
+		if ts, err := time.Parse(timestampLayout, logEntry.Timestamp); err == nil {
+			acc.addErrorTimestamp(ts)
+		}
+	}
+	if opts.Stats.has(statStatusClasses) {
+		switch statusClass(logEntry.StatusCode) {
+		case 2:
+			stats.SuccessCount++
+		case 3:
+			stats.RedirectCount++
+		}
+		stats.RequestsByStatus[statusKey(logEntry.StatusCode, opts.StatusByClass)]++
+	}
+	if stats.RequestsByIP != nil {
+		stats.RequestsByIP[logEntry.IP]++
+	}
+	if stats.RequestsByMethod != nil {
+		stats.RequestsByMethod[logEntry.Method]++
+	}
+	if stats.RequestsByURL != nil {
+		key := urlKey(logEntry.URL, opts.URLPrefixSegments)
+		stats.RequestsByURL[key]++
+		if stats.URLStatusClasses != nil {
+			counts := stats.URLStatusClasses[key]
+			if counts == nil {
+				counts = &statusClassCounts{}
+				stats.URLStatusClasses[key] = counts
+			}
+			counts[statusClass(logEntry.StatusCode)]++
+		}
+	}
+	if stats.ErrorsByURL != nil && acc.isError(logEntry) {
+		stats.ErrorsByURL[urlKey(logEntry.URL, opts.URLPrefixSegments)]++
+	}
+	if stats.BytesByIP != nil {
+		stats.BytesByIP[logEntry.IP] += logEntry.Bytes
+	}
+	if stats.RespTimeByURL != nil {
+		stats.RespTimeByURL[urlKey(logEntry.URL, opts.URLPrefixSegments)] += logEntry.ResponseTime
+	}
+	if stats.CacheStatusCounts != nil && logEntry.CacheStatus != "" {
+		stats.CacheStatusCounts[logEntry.CacheStatus]++
+	}
+	if stats.RequestsByReferer != nil {
+		stats.RequestsByReferer[valueOrNone(logEntry.Referer)]++
+	}
+	if stats.RequestsByUA != nil {
+		stats.RequestsByUA[valueOrNone(logEntry.UserAgent)]++
+	}
+	if stats.RequestsByEndpoint != nil {
+		stats.RequestsByEndpoint[logEntry.Method+" "+urlKey(logEntry.URL, opts.URLPrefixSegments)]++
+	}
+	if opts.ErrorLog != nil && statusClass(logEntry.StatusCode) == 5 {
+		acc.serverErrors = append(acc.serverErrors, logEntry)
+	}
+	if stats.RequestsByParam != nil {
+		stats.RequestsByParam[queryParamValue(logEntry.URL, opts.GroupByParam)]++
+	}
+	acc.totalRespTime += logEntry.ResponseTime
+	acc.respTimeVariance.add(logEntry.ResponseTime)
+	if opts.SlowThreshold > 0 && logEntry.ResponseTime > durationMillis(opts.SlowThreshold) {
+		stats.SlowCount++
+	}
+	if opts.FastThreshold > 0 && logEntry.ResponseTime < durationMillis(opts.FastThreshold) {
+		stats.FastCount++
+	}
+	if acc.respTimeCounts != nil {
+		acc.respTimeCounts[respTimeBucket(logEntry.ResponseTime)]++
+		if len(acc.slowest) < slowestCount || logEntry.ResponseTime > acc.slowest[len(acc.slowest)-1].ResponseTime {
+			acc.slowest = keepSlowest(append(acc.slowest, logEntry))
+		}
+	}
+	for i, target := range opts.SLOs {
+		if target.matches(logEntry) {
+			acc.sloRespTimeCounts[i][respTimeBucket(logEntry.ResponseTime)]++
+			if logEntry.ResponseTime <= target.Latency {
+				acc.sloWithinTarget[i]++
+			}
+		}
+	}
+	if opts.Stats.has(statAvgTime) {
+		class := statusClass(logEntry.StatusCode)
+		stats.RequestsByClass[class]++
+		acc.classRespTime[class] += logEntry.ResponseTime
+	}
+
+	// Записи с нераспознанным временем в расчете периода, пиковой нагрузки и пропусков не участвуют
+	if opts.Stats&statNeedsTimestamp != 0 || acc.requestsPerGapBucket != nil || acc.requestsPerSpikeBucket != nil || opts.Heatmap {
+		if ts, err := time.Parse(timestampLayout, logEntry.Timestamp); err == nil {
+			acc.addTimestamp(ts)
+			if acc.requestsPerSecond != nil {
+				acc.requestsPerSecond[ts.Unix()]++
+			}
+			if opts.Stats.has(statHourOfDay) {
+				stats.RequestsByHour[ts.Hour()]++
+			}
+			if acc.requestsPerGapBucket != nil {
+				acc.requestsPerGapBucket[ts.Unix()/gapIntervalSeconds(opts.GapInterval)]++
+			}
+			if acc.requestsPerSpikeBucket != nil {
+				acc.requestsPerSpikeBucket[ts.Unix()/gapIntervalSeconds(opts.SpikeInterval)]++
+			}
+			if opts.Heatmap {
+				stats.RequestsByWeekdayHour[weekdayIndex(ts.Weekday())][ts.Hour()]++
+			}
+		}
+	}
+}
+
+// Расширяем период, который охватывают логи, до момента ts
+func (acc *statsAccumulator) addTimestamp(ts time.Time) {
+	if acc.stats.FirstTimestamp.IsZero() || ts.Before(acc.stats.FirstTimestamp) {
+		acc.stats.FirstTimestamp = ts
+	}
+	if ts.After(acc.stats.LastTimestamp) {
+		acc.stats.LastTimestamp = ts
+	}
+}
+
+// Расширяем период, в который приходились ошибки, до момента ts
+func (acc *statsAccumulator) addErrorTimestamp(ts time.Time) {
+	if acc.stats.FirstErrorAt.IsZero() || ts.Before(acc.stats.FirstErrorAt) {
+		acc.stats.FirstErrorAt = ts
+	}
+	if ts.After(acc.stats.LastErrorAt) {
+		acc.stats.LastErrorAt = ts
+	}
+}
+
+// Добавляем к накопителю данные другого накопителя с теми же настройками
+func (acc *statsAccumulator) Merge(other *statsAccumulator) {
+	stats := &acc.stats
+	stats.TotalRequests += other.stats.TotalRequests
+	stats.ErrorCount += other.stats.ErrorCount
+	stats.SuccessCount += other.stats.SuccessCount
+	stats.RedirectCount += other.stats.RedirectCount
+	stats.SlowCount += other.stats.SlowCount
+	stats.FastCount += other.stats.FastCount
+	acc.totalRespTime += other.totalRespTime
+	acc.respTimeVariance.merge(other.respTimeVariance)
+	mergeCounts(acc.respTimeCounts, other.respTimeCounts)
+	if acc.respTimeCounts != nil {
+		acc.slowest = keepSlowest(append(acc.slowest, other.slowest...))
+	}
+	acc.serverErrors = append(acc.serverErrors, other.serverErrors...)
+	for class := range acc.classRespTime {
+		stats.RequestsByClass[class] += other.stats.RequestsByClass[class]
+		acc.classRespTime[class] += other.classRespTime[class]
+	}
+	for hour, count := range other.stats.RequestsByHour {
+		stats.RequestsByHour[hour] += count
+	}
+	for day := range stats.RequestsByWeekdayHour {
+		for hour, count := range other.stats.RequestsByWeekdayHour[day] {
+			stats.RequestsByWeekdayHour[day][hour] += count
+		}
+	}
+
+	mergeCounts(stats.RequestsByStatus, other.stats.RequestsByStatus)
+	mergeCounts(stats.RequestsByIP, other.stats.RequestsByIP)
+	mergeCounts(stats.RequestsByMethod, other.stats.RequestsByMethod)
+	mergeCounts(stats.RequestsByURL, other.stats.RequestsByURL)
+	mergeCounts(stats.RequestsByEndpoint, other.stats.RequestsByEndpoint)
+	mergeCounts(stats.RequestsByParam, other.stats.RequestsByParam)
+	mergeCounts(stats.ErrorsByURL, other.stats.ErrorsByURL)
+	mergeCounts(stats.BytesByIP, other.stats.BytesByIP)
+	mergeCounts(stats.RespTimeByURL, other.stats.RespTimeByURL)
+	mergeCounts(stats.CacheStatusCounts, other.stats.CacheStatusCounts)
+	mergeCounts(stats.RequestsByReferer, other.stats.RequestsByReferer)
+	mergeCounts(stats.RequestsByUA, other.stats.RequestsByUA)
+	mergeCounts(acc.requestsPerSecond, other.requestsPerSecond)
+	mergeCounts(acc.requestsPerGapBucket, other.requestsPerGapBucket)
+	mergeCounts(acc.requestsPerSpikeBucket, other.requestsPerSpikeBucket)
+	for i, counts := range other.sloRespTimeCounts {
+		mergeCounts(acc.sloRespTimeCounts[i], counts)
+		acc.sloWithinTarget[i] += other.sloWithinTarget[i]
+	}
+
+	for key, counts := range other.stats.URLStatusClasses {
+		if existing := stats.URLStatusClasses[key]; existing != nil {
+			for class := range counts {
+				existing[class] += counts[class]
+			}
+			continue
+		}
+		stats.URLStatusClasses[key] = counts
+	}
+
+	if !other.stats.FirstTimestamp.IsZero() {
+		acc.addTimestamp(other.stats.FirstTimestamp)
+		acc.addTimestamp(other.stats.LastTimestamp)
+	}
+	if !other.stats.FirstErrorAt.IsZero() {
+		acc.addErrorTimestamp(other.stats.FirstErrorAt)
+		acc.addErrorTimestamp(other.stats.LastErrorAt)
+	}
+}
+
+// Доля ответов из кэша CDN (HIT) среди ответов с известным статусом кэша
+func cacheHitRatio(counts map[string]int) float64 {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(counts["HIT"]) / float64(total)
+}
+
+// Сколько значащих цифр времени ответа сохраняется в распределениях для перцентилей
+// SLO и порога аномалий: относительная погрешность не больше 0,5%
+const respTimeSignificantDigits = 3
+
+// Округляем время ответа до respTimeSignificantDigits значащих цифр (0.4271 → 0.427,
+// 1234 → 1230). Дробное время почти не повторяется, и без округления в картах
+// распределения было бы по ключу на каждую запись; после округления ключей не больше
+// 900 на каждый десятичный порядок значений.
+func respTimeBucket(ms float64) float64 {
+	if ms == 0 || math.IsInf(ms, 0) || math.IsNaN(ms) {
+		return ms
+	}
+	// Десятичных знаков после запятой, до которых округляем (отрицательное — до десятков,
+	// сотен и т.д.); делим и умножаем на целые степени 10, чтобы результат был точным
+	decimals := respTimeSignificantDigits - 1 - int(math.Floor(math.Log10(math.Abs(ms))))
+	if decimals >= 0 {
+		scale := math.Pow10(decimals)
+		return math.Round(ms*scale) / scale
+	}
+	unit := math.Pow10(-decimals)
+	return math.Round(ms/unit) * unit
+}
+
+// Длительность в миллисекундах (в единицах времени ответа LogEntry)
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Ключ для пустого значения необязательного поля (нет колонки в схеме или значение пустое)
+const noneKey = "<none>"
+
+// Значение поля как ключ карты: пустое заменяется на noneKey
+func valueOrNone(value string) string {
+	if value == "" {
+		return noneKey
+	}
+	return value
+}
+
+// Прибавляем счетчики (или суммы) src к dst (dst == nil — показатель не вычисляется)
+func mergeCounts[K comparable, V int | float64](dst, src map[K]V) {
+	if dst == nil {
+		return
+	}
+	for key, count := range src {
+		dst[key] += count
+	}
+}
+
+// Итоговая статистика: вычисляем производные показатели (среднее, пик)
+func (acc *statsAccumulator) Result() Statistics {
+	stats := acc.stats
+
+	// Пиковая нагрузка — секунда с наибольшим количеством запросов
+	// (при равенстве берется более ранняя)
+	for second, count := range acc.requestsPerSecond {
+		if count > stats.PeakRate || (count == stats.PeakRate && second < stats.PeakSecond.Unix()) {
+			stats.PeakRate = count
+			stats.PeakSecond = time.Unix(second, 0).UTC()
+		}
+	}
+
+	if acc.requestsPerGapBucket != nil {
+		stats.CoverageGaps = coverageGaps(acc.requestsPerGapBucket, acc.opts.GapInterval)
+	}
+	if acc.requestsPerSpikeBucket != nil {
+		stats.Spikes = trafficSpikes(acc.requestsPerSpikeBucket, acc.opts.SpikeInterval, acc.opts.SpikeFactor)
+	}
+
+	for i, target := range acc.opts.SLOs {
+		stats.SLOResults = append(stats.SLOResults, evaluateSLO(target, acc.sloRespTimeCounts[i], acc.sloWithinTarget[i]))
+	}
+
+	if acc.opts.Stats.has(statAvgTime) && stats.TotalRequests > 0 {
+		stats.AverageRespTime = acc.totalRespTime / float64(stats.TotalRequests)
+	}
+	stats.StdDevRespTime = acc.respTimeVariance.stddev()
+
+	// Аномалии задержки: время ответа больше среднего на AnomalySigma стандартных отклонений
+	if acc.respTimeCounts != nil {
+		stats.AnomalyThreshold = acc.respTimeVariance.mean + acc.opts.AnomalySigma*stats.StdDevRespTime
+		for respTime, count := range acc.respTimeCounts {
+			if respTime > stats.AnomalyThreshold {
+				stats.AnomalyCount += count
+			}
+		}
+		for _, logEntry := range acc.slowest {
+			if logEntry.ResponseTime > stats.AnomalyThreshold {
+				stats.Anomalies = append(stats.Anomalies, logEntry)
+			}
+		}
+	}
+
+	for class, count := range stats.RequestsByClass {
+		if count > 0 {
+			stats.AvgRespTimeByClass[class] = acc.classRespTime[class] / float64(count)
+		}
+	}
+
+	if stats.RequestsByIP != nil {
+		stats.IPGini = giniCoefficient(stats.RequestsByIP)
+	}
+
+	if acc.opts.ErrorLog != nil {
+		stats.ErrorCorrelations = correlateErrors(acc.serverErrors, acc.opts.ErrorLog, acc.opts.ErrorLogWindow)
+	}
+
+	return stats
+}
+
+// Период без запросов: [Start, End)
+type timeGap struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Длина интервала (--gap-interval, --spike-interval) в целых секундах (время в логах
+// с точностью до секунды)
+func gapIntervalSeconds(interval time.Duration) int64 {
+	return max(int64(interval/time.Second), 1)
+}
+
+// Пропуски в логах: между первым и последним интервалом с запросами — серии интервалов
+// без запросов, каждая серия одним периодом. Границы периодов кратны длине интервала.
+func coverageGaps(requestsPerBucket map[int64]int, interval time.Duration) []timeGap {
+	size := gapIntervalSeconds(interval)
+	buckets := slices.Sorted(maps.Keys(requestsPerBucket))
+	var gaps []timeGap
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i]-buckets[i-1] > 1 {
+			gaps = append(gaps, timeGap{
+				Start: time.Unix((buckets[i-1]+1)*size, 0).UTC(),
+				End:   time.Unix(buckets[i]*size, 0).UTC(),
+			})
+		}
+	}
+	return gaps
+}
+
+// Сколько предыдущих интервалов входит в скользящую медиану для поиска всплесков
+const spikeWindow = 10
+
+// Всплеск нагрузки: интервал, начинающийся в Start, с Count запросами при медиане
+// предыдущих интервалов Baseline
+type trafficSpike struct {
+	Start    time.Time
+	Count    int
+	Baseline float64
+}
+
+// Всплески нагрузки (--spike-factor): интервалы, в которых запросов больше медианы
+// spikeWindow предыдущих интервалов в factor раз, по возрастанию времени. Интервалы без
+// запросов между первым и последним учитываются в медиане как нулевые; медиана меньше 1
+// считается равной 1, чтобы единичные запросы после затишья не были всплеском. Первые
+// интервалы, для которых еще нет полного окна, не проверяются.
+func trafficSpikes(requestsPerBucket map[int64]int, interval time.Duration, factor float64) []trafficSpike {
+	if len(requestsPerBucket) == 0 {
+		return nil
+	}
+	size := gapIntervalSeconds(interval)
+	buckets := slices.Sorted(maps.Keys(requestsPerBucket))
+	first, last := buckets[0], buckets[len(buckets)-1]
+
+	var spikes []trafficSpike
+	window := make([]float64, 0, spikeWindow)
+	for bucket := first; bucket <= last; bucket++ {
+		count := requestsPerBucket[bucket]
+		if len(window) == spikeWindow {
+			baseline := median(window)
+			if float64(count) > factor*max(baseline, 1) {
+				spikes = append(spikes, trafficSpike{
+					Start:    time.Unix(bucket*size, 0).UTC(),
+					Count:    count,
+					Baseline: baseline,
+				})
+			}
+			window = window[1:]
+		}
+		window = append(window, float64(count))
+	}
+	return spikes
+}
+
+// Медиана значений (values не изменяется)
+func median(values []float64) float64 {
+	sorted := slices.Sorted(slices.Values(values))
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// Коэффициент Джини распределения количеств: 0 — все ключи получили поровну,
+// чем ближе к 1, тем сильнее все сосредоточено на немногих ключах
+// (для n ключей наибольшее значение — (n-1)/n, когда все досталось одному).
+// Считается по отсортированным количествам: G = 2·Σ i·xᵢ / (n·Σ xᵢ) − (n+1)/n.
+func giniCoefficient(counts map[string]int) float64 {
+	values := slices.Sorted(maps.Values(counts))
+	n := len(values)
+	var total, weighted float64
+	for i, v := range values {
+		total += float64(v)
+		weighted += float64(i+1) * float64(v)
+	}
+	if n == 0 || total == 0 {
+		return 0
+	}
+	return 2*weighted/(float64(n)*total) - float64(n+1)/float64(n)
+}
+
+// Сколько самых медленных записей выводится в отчете об аномалиях задержки
+const slowestCount = 5
+
+// Оставляем slowestCount самых медленных записей (по убыванию времени ответа)
+func keepSlowest(entries []LogEntry) []LogEntry {
+	slices.SortStableFunc(entries, func(a, b LogEntry) int {
+		return cmp.Compare(b.ResponseTime, a.ResponseTime)
+	})
+	return entries[:min(len(entries), slowestCount)]
+}
+
+// Онлайн-вычисление среднего и дисперсии (алгоритм Уэлфорда): значения
+// добавляются по одному без хранения, результат численно устойчив
+type welford struct {
+	n    int
+	mean float64
+	m2   float64 // сумма квадратов отклонений от среднего
+}
+
+// Добавляем значение x
+func (w *welford) add(x float64) {
+	w.n++
+	delta := x - w.mean
+	w.mean += delta / float64(w.n)
+	w.m2 += delta * (x - w.mean)
+}
+
+// Объединяем с результатом по другой части данных (формула Чана для параллельного подсчета)
+func (w *welford) merge(other welford) {
+	if other.n == 0 {
+		return
+	}
+	n := w.n + other.n
+	delta := other.mean - w.mean
+	w.m2 += other.m2 + delta*delta*float64(w.n)*float64(other.n)/float64(n)
+	w.mean += delta * float64(other.n) / float64(n)
+	w.n = n
+}
+
+// Стандартное отклонение (по всей совокупности значений)
+func (w *welford) stddev() float64 {
+	if w.n == 0 {
+		return 0
+	}
+	return math.Sqrt(w.m2 / float64(w.n))
+}
+
+// Подсчет статистики в shards параллельных накопителях. Записи распределяются
+// по накопителям по хешу IP (hash(ip) % shards), поэтому карты RequestsByIP
+// у накопителей не пересекаются и обновляются без общей точки сериализации.
+// В конце накопители объединяются через Merge.
+func calculateStatsSharded(input <-chan LogEntry, opts Options, shards int) Statistics {
+	if shards <= 1 {
+		return calculateStats(input, opts)
+	}
+
+	accumulators := make([]*statsAccumulator, shards)
+	channels := make([]chan LogEntry, shards)
+	var wg sync.WaitGroup
+	wg.Add(shards)
+	for i := range shards {
+		accumulators[i] = newStatsAccumulator(opts)
+		channels[i] = make(chan LogEntry, 100)
+		go func(acc *statsAccumulator, shardInput <-chan LogEntry) {
+			defer wg.Done()
+			for logEntry := range shardInput {
+				acc.Add(logEntry)
+			}
+		}(accumulators[i], channels[i])
+	}
+
+	// Распределяем записи по накопителям
+	for logEntry := range input {
+		channels[ipShard(logEntry.IP, shards)] <- logEntry
+	}
+	for _, ch := range channels {
+		close(ch)
+	}
+	wg.Wait()
+
+	for _, acc := range accumulators[1:] {
+		accumulators[0].Merge(acc)
+	}
+	return accumulators[0].Result()
+}
+
+// Номер накопителя для IP адреса
+func ipShard(ip string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(ip))
+	return int(h.Sum32() % uint32(shards))
+}
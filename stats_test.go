@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// Записи для тестов статистики
+var testEntries = []LogEntry{
+	{Timestamp: "2024-01-15 10:30:00", IP: "10.0.0.1", Method: "GET", URL: "/api/users", StatusCode: 200, ResponseTime: 100},
+	{Timestamp: "2024-01-15 10:30:01", IP: "10.0.0.1", Method: "POST", URL: "/api/users", StatusCode: 201, ResponseTime: 200},
+	{Timestamp: "2024-01-15 10:30:02", IP: "10.0.0.2", Method: "GET", URL: "/api/users/1?x=1", StatusCode: 404, ResponseTime: 50},
+	{Timestamp: "2024-01-15 10:30:02", IP: "10.0.0.3", Method: "GET", URL: "/api/products", StatusCode: 500, ResponseTime: 1000},
+	{Timestamp: "2024-01-15 10:30:03", IP: "10.0.0.1", Method: "GET", URL: "/api/users/", StatusCode: 302, ResponseTime: 50},
+}
+
+// Канал с записями entries, как на выходе стадий pipeline
+func entriesChan(entries []LogEntry, buffer int) <-chan LogEntry {
+	input := make(chan LogEntry, buffer)
+	go func() {
+		defer close(input)
+		for _, logEntry := range entries {
+			input <- logEntry
+		}
+	}()
+	return input
+}
+
+// Подсчет статистики через канал, как в pipeline
+func calculateTestStats(entries []LogEntry, opts Options) Statistics {
+	return calculateStats(entriesChan(entries, 0), opts)
+}
+
+func TestCalculateStats(t *testing.T) {
+	stats := calculateTestStats(testEntries, defaultOptions())
+
+	if stats.TotalRequests != 5 {
+		t.Errorf("TotalRequests = %d, ожидалось 5", stats.TotalRequests)
+	}
+	if stats.ErrorCount != 2 {
+		t.Errorf("ErrorCount = %d, ожидалось 2", stats.ErrorCount)
+	}
+	if stats.SuccessCount != 2 || stats.RedirectCount != 1 {
+		t.Errorf("SuccessCount, RedirectCount = %d, %d, ожидалось 2, 1", stats.SuccessCount, stats.RedirectCount)
+	}
+	if stats.AverageRespTime != 280 {
+		t.Errorf("AverageRespTime = %g, ожидалось 280", stats.AverageRespTime)
+	}
+	wantIPs := map[string]int{"10.0.0.1": 3, "10.0.0.2": 1, "10.0.0.3": 1}
+	if !reflect.DeepEqual(stats.RequestsByIP, wantIPs) {
+		t.Errorf("RequestsByIP = %v, ожидалось %v", stats.RequestsByIP, wantIPs)
+	}
+	wantURLs := map[string]int{"/api/users": 3, "/api/users/1": 1, "/api/products": 1}
+	if !reflect.DeepEqual(stats.RequestsByURL, wantURLs) {
+		t.Errorf("RequestsByURL = %v, ожидалось %v", stats.RequestsByURL, wantURLs)
+	}
+	wantMethods := map[string]int{"GET": 4, "POST": 1}
+	if !reflect.DeepEqual(stats.RequestsByMethod, wantMethods) {
+		t.Errorf("RequestsByMethod = %v, ожидалось %v", stats.RequestsByMethod, wantMethods)
+	}
+	if stats.PeakRate != 2 {
+		t.Errorf("PeakRate = %d, ожидалось 2", stats.PeakRate)
+	}
+}
+
+// Пустой вход: все показатели нулевые, без деления на ноль (NaN)
+func TestCalculateStatsEmpty(t *testing.T) {
+	opts := defaultOptions()
+	opts.Verbose = true
+	stats := calculateStats(entriesChan(nil, 0), opts)
+
+	if stats.TotalRequests != 0 || stats.ErrorCount != 0 {
+		t.Errorf("TotalRequests, ErrorCount = %d, %d, ожидалось 0, 0", stats.TotalRequests, stats.ErrorCount)
+	}
+	if stats.AverageRespTime != 0 {
+		t.Errorf("AverageRespTime = %g, ожидалось 0", stats.AverageRespTime)
+	}
+	if stats.StdDevRespTime != 0 {
+		t.Errorf("StdDevRespTime = %g, ожидалось 0", stats.StdDevRespTime)
+	}
+	for class, avg := range stats.AvgRespTimeByClass {
+		if avg != 0 {
+			t.Errorf("AvgRespTimeByClass[%d] = %g, ожидалось 0", class, avg)
+		}
+	}
+	if stats.IPGini != 0 {
+		t.Errorf("IPGini = %g, ожидалось 0", stats.IPGini)
+	}
+}
+
+func TestCalculateStatsSelection(t *testing.T) {
+	opts := defaultOptions()
+	opts.Stats = statTotal
+	stats := calculateTestStats(testEntries, opts)
+	if stats.TotalRequests != 5 {
+		t.Errorf("TotalRequests = %d, ожидалось 5", stats.TotalRequests)
+	}
+	if stats.ErrorCount != 0 || stats.RequestsByIP != nil || stats.AverageRespTime != 0 {
+		t.Errorf("посчитаны невыбранные показатели: %+v", stats)
+	}
+}
+
+// Результат Merge совпадает с подсчетом за один проход при любом разбиении записей
+func TestStatsAccumulatorMerge(t *testing.T) {
+	opts := defaultOptions()
+	opts.Verbose = true
+	want := CalculateStatsSlice(testEntries, opts)
+
+	for split := range len(testEntries) + 1 {
+		t.Run(fmt.Sprint(split), func(t *testing.T) {
+			first, second := newStatsAccumulator(opts), newStatsAccumulator(opts)
+			for _, logEntry := range testEntries[:split] {
+				first.Add(logEntry)
+			}
+			for _, logEntry := range testEntries[split:] {
+				second.Add(logEntry)
+			}
+			first.Merge(second)
+			assertStatsEqual(t, first.Result(), want)
+		})
+	}
+}
+
+// Сравниваем статистику; стандартное отклонение — с допуском на погрешность
+// объединения дисперсий
+func assertStatsEqual(t *testing.T, got, want Statistics) {
+	t.Helper()
+	if math.Abs(got.StdDevRespTime-want.StdDevRespTime) > 1e-9 {
+		t.Errorf("StdDevRespTime = %g, ожидалось %g", got.StdDevRespTime, want.StdDevRespTime)
+	}
+	got.StdDevRespTime, want.StdDevRespTime = 0, 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("статистика отличается:\n%+v\nожидалось:\n%+v", got, want)
+	}
+}
+
+// Записи для сравнения подсчета в одном и нескольких накопителях
+func generateTestEntries(n int) []LogEntry {
+	entries := make([]LogEntry, n)
+	for i := range entries {
+		entries[i] = LogEntry{
+			Timestamp:    fmt.Sprintf("2024-01-15 10:%02d:%02d", i/60%60, i%60),
+			IP:           fmt.Sprintf("10.0.%d.%d", i%11, i%251),
+			Method:       []string{"GET", "POST", "PUT"}[i%3],
+			URL:          fmt.Sprintf("/api/item/%d", i%97),
+			StatusCode:   []int{200, 201, 302, 404, 500}[i%5],
+			ResponseTime: float64(i % 1000),
+		}
+	}
+	return entries
+}
+
+// Подсчет в shards накопителях дает ту же статистику, что и в одном
+func TestCalculateStatsShardedEqual(t *testing.T) {
+	entries := generateTestEntries(10000)
+	opts := defaultOptions()
+	want := calculateTestStats(entries, opts)
+	for _, shards := range []int{2, 3, 8} {
+		t.Run(fmt.Sprint(shards), func(t *testing.T) {
+			assertStatsEqual(t, calculateStatsSharded(entriesChan(entries, 0), opts, shards), want)
+		})
+	}
+}
+
+func benchmarkStats(b *testing.B, shards int) {
+	entries := generateTestEntries(100000)
+	opts := defaultOptions()
+	b.ReportAllocs()
+	for b.Loop() {
+		calculateStatsSharded(entriesChan(entries, 100), opts, shards)
+	}
+}
+
+func BenchmarkCalculateStats(b *testing.B) {
+	benchmarkStats(b, 1)
+}
+
+func BenchmarkCalculateStatsSharded(b *testing.B) {
+	benchmarkStats(b, 4)
+}
+
+// Сумма времени ответа не переполняется: суммарно 10 записей по 1e18 ms больше
+// math.MaxInt64, а 3e9 ms больше math.MaxInt32 (переполнение int на 32-битных платформах)
+func TestCalculateStatsLargeResponseTimes(t *testing.T) {
+	for _, respTime := range []float64{3e9, 1e18} {
+		t.Run(fmt.Sprint(respTime), func(t *testing.T) {
+			entries := make([]LogEntry, 10)
+			summary := &hourSummary{}
+			isError := errorFilter(defaultOptions())
+			for i := range entries {
+				entries[i] = LogEntry{Timestamp: "2024-01-15 10:30:00", StatusCode: 200, ResponseTime: respTime}
+				summary.add(entries[i], isError)
+			}
+
+			stats := calculateTestStats(entries, defaultOptions())
+			if stats.AverageRespTime != respTime {
+				t.Errorf("AverageRespTime = %g, ожидалось %g", stats.AverageRespTime, respTime)
+			}
+			if stats.AvgRespTimeByClass[2] != respTime {
+				t.Errorf("AvgRespTimeByClass[2] = %g, ожидалось %g", stats.AvgRespTimeByClass[2], respTime)
+			}
+			if summary.AverageRespTime != respTime {
+				t.Errorf("среднее в почасовой сводке = %g, ожидалось %g", summary.AverageRespTime, respTime)
+			}
+		})
+	}
+}
+
+func TestRespTimeBucket(t *testing.T) {
+	tests := []struct {
+		ms, want float64
+	}{
+		{0, 0},
+		{150, 150},
+		{999, 999},
+		{0.427, 0.427},
+		{0.4271, 0.427},
+		{0.00123456, 0.00123},
+		{12.345, 12.3},
+		{1234, 1230},
+		{1235.5, 1240},
+		{5000, 5000},
+		{98765432, 98800000},
+		{-5.678, -5.68},
+	}
+	for _, tt := range tests {
+		if got := respTimeBucket(tt.ms); got != tt.want {
+			t.Errorf("respTimeBucket(%g) = %g, ожидалось %g", tt.ms, got, tt.want)
+		}
+	}
+}
+
+// Распределения для перцентилей SLO и аномалий не растут с каждым новым дробным
+// значением времени ответа, а перцентиль остается точным до 0,5%
+func TestRespTimeCountsBounded(t *testing.T) {
+	opts := defaultOptions()
+	opts.AnomalySigma = 3
+	opts.SLOs = []sloTarget{{URL: "/*", Latency: 500.005, Percentile: 99}}
+	acc := newStatsAccumulator(opts)
+
+	// 100 000 разных значений от 1 до 1001 ms
+	const n = 100000
+	for i := range n {
+		acc.Add(LogEntry{URL: "/a", StatusCode: 200, ResponseTime: 1 + float64(i)/100 + 0.000123})
+	}
+	// Три десятичных порядка (1–10, 10–100, 100–1000) по 900 значений и граница 1000–1001
+	if got := len(acc.respTimeCounts); got > 3*900+2 {
+		t.Errorf("ключей в распределении аномалий %d, ожидалось не больше %d", got, 3*900+2)
+	}
+	if got := len(acc.sloRespTimeCounts[0]); got > 3*900+2 {
+		t.Errorf("ключей в распределении SLO %d, ожидалось не больше %d", got, 3*900+2)
+	}
+
+	stats := acc.Result()
+	exact := 1 + float64(n*99/100-1)/100 + 0.000123
+	if actual := stats.SLOResults[0].Actual; math.Abs(actual-exact)/exact > 0.005 {
+		t.Errorf("p99 = %g, точное значение %g", actual, exact)
+	}
+	if stats.SLOResults[0].Requests != n {
+		t.Errorf("запросов цели %d, ожидалось %d", stats.SLOResults[0].Requests, n)
+	}
+	// Запросы не дольше цели считаются без округления: 1.000123…500.000123 ms,
+	// хотя 500.010123 ms попадает в ту же корзину 500
+	if got := stats.SLOResults[0].WithinTarget; got != 49901 {
+		t.Errorf("не дольше цели %d, ожидалось 49901", got)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Сколько ждать завершения запросов к /stats при остановке сервера
+const statusShutdownTimeout = 5 * time.Second
+
+// Запускаем HTTP сервер статуса (--status-addr): GET /stats отдает текущую
+// статистику в JSON, не прерывая обработку, GET /counters — только атомарные
+// счетчики, без блокировки накопителя (подходит для частого опроса). Адрес
+// занимается сразу, чтобы ошибка (например, занятый порт) обнаружилась до начала
+// обработки. Сервер останавливается при отмене контекста или вызове stop.
+func startStatusServer(ctx context.Context, addr string, live *liveStats) (stop func(), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := live.writeJSON(w); err != nil {
+			log.Printf("ошибка ответа на /stats: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /counters", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(live.counters.snapshot()); err != nil {
+			log.Printf("ошибка ответа на /counters: %v", err)
+		}
+	})
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("ошибка HTTP сервера статуса: %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), statusShutdownTimeout)
+		defer cancelShutdown()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
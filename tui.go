@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Как часто TUI обновляет счетчики и добавляет точку в спарклайн задержки
+const tuiRefreshInterval = 250 * time.Millisecond
+
+// Сколько последних интервалов показывает спарклайн
+const sparklineWidth = 60
+
+// Сколько строк показывают таблицы TUI
+const tuiTopN = 10
+
+// Статистика, обновляемая по мере прохождения записей (--tui, --status-addr).
+// Накопитель защищен мьютексом: записи учитывает стадия pipeline, а снимки
+// снимают горутина TUI и обработчик HTTP /stats. Самые частые показатели
+// дублируются в атомарных счетчиках: их читают без мьютекса, не мешая стадии.
+type liveStats struct {
+	counters liveCounters
+
+	mu  sync.Mutex
+	acc *statsAccumulator
+
+	// Сумма времени ответа и количество записей с последнего снимка (для спарклайна)
+	intervalRespTime float64
+	intervalCount    int
+}
+
+// Атомарные счетчики записей: общее количество, ошибки и классы статусов.
+// Обновляются и читаются без блокировок.
+type liveCounters struct {
+	total   atomic.Int64
+	errors  atomic.Int64
+	byClass [6]atomic.Int64 // индексы как у statusClassCounts
+}
+
+// Значения счетчиков в JSON (GET /counters)
+type liveCountersSnapshot struct {
+	Total   int64            `json:"total"`
+	Errors  int64            `json:"errors"`
+	ByClass map[string]int64 `json:"by_class"`
+}
+
+// Учитываем запись в счетчиках
+func (c *liveCounters) add(logEntry LogEntry, isError logPredicate) {
+	c.total.Add(1)
+	if isError(logEntry) {
+		c.errors.Add(1)
+	}
+	c.byClass[statusClass(logEntry.StatusCode)].Add(1)
+}
+
+// Текущие значения счетчиков. Счетчики читаются по отдельности, поэтому при
+// одновременном обновлении сумма по классам может на несколько записей расходиться с total.
+func (c *liveCounters) snapshot() liveCountersSnapshot {
+	s := liveCountersSnapshot{
+		Total:   c.total.Load(),
+		Errors:  c.errors.Load(),
+		ByClass: make(map[string]int64),
+	}
+	for class := range c.byClass {
+		if count := c.byClass[class].Load(); count > 0 {
+			name := "other"
+			if class > 0 {
+				name = fmt.Sprintf("%dxx", class)
+			}
+			s.ByClass[name] = count
+		}
+	}
+	return s
+}
+
+// Снимок статистики для одного кадра TUI
+type liveSnapshot struct {
+	Total      int
+	Errors     int
+	AvgTime    float64
+	IntervalOK bool    // были ли записи с прошлого снимка
+	Interval   float64 // среднее время ответа за интервал с прошлого снимка
+	TopIPs     []keyCount
+	TopURLs    []keyCount
+	Methods    []keyCount
+}
+
+// Новая живая статистика с показателями из opts
+func newLiveStats(opts Options) *liveStats {
+	return &liveStats{acc: newStatsAccumulator(opts)}
+}
+
+// Настройки живой статистики только для TUI: считаются лишь показатели, которые
+// показывает экран
+func tuiStatsOptions(opts Options) Options {
+	opts.Stats = statTotal | statErrors | statAvgTime | statTopIPs | statTopURLs | statMethods
+	opts.Verbose = false
+	opts.AnomalySigma = 0
+	opts.SLOs = nil
+	opts.ErrorLog = nil
+	opts.GroupByParam = ""
+	return opts
+}
+
+// Стадия pipeline: учитываем каждую запись в живой статистике и передаем дальше без изменений
+func (l *liveStats) watch(input <-chan LogEntry) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		for logEntry := range input {
+			l.counters.add(logEntry, l.acc.isError)
+			l.mu.Lock()
+			l.acc.Add(logEntry)
+			l.intervalRespTime += logEntry.ResponseTime
+			l.intervalCount++
+			l.mu.Unlock()
+
+			out <- logEntry
+		}
+	}()
+
+	return out
+}
+
+// Текущая статистика в JSON. Кодируется под мьютексом: карты статистики
+// общие с накопителем, который продолжает их обновлять.
+func (l *liveStats) writeJSON(w io.Writer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return json.NewEncoder(w).Encode(l.acc.Result())
+}
+
+// Снимаем текущие значения и начинаем новый интервал для спарклайна
+func (l *liveStats) snapshot() liveSnapshot {
+	s := liveSnapshot{
+		Total:  int(l.counters.total.Load()),
+		Errors: int(l.counters.errors.Load()),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := &l.acc.stats
+	s.IntervalOK = l.intervalCount > 0
+	s.TopIPs = topN(stats.RequestsByIP, tuiTopN)
+	s.TopURLs = topN(stats.RequestsByURL, tuiTopN)
+	s.Methods = topN(stats.RequestsByMethod, tuiTopN)
+	if stats.TotalRequests > 0 {
+		s.AvgTime = l.acc.totalRespTime / float64(stats.TotalRequests)
+	}
+	if l.intervalCount > 0 {
+		s.Interval = l.intervalRespTime / float64(l.intervalCount)
+	}
+	l.intervalRespTime, l.intervalCount = 0, 0
+	return s
+}
+
+// Таблицы, между которыми переключаются стрелки влево/вправо
+var tuiViews = []string{"IP", "URL", "Методы"}
+
+// Состояние экрана TUI
+type tuiModel struct {
+	title     string
+	live      *liveStats
+	snapshot  liveSnapshot
+	latencies []float64 // среднее время ответа по интервалам (для спарклайна)
+	view      int
+	done      bool
+}
+
+// Обновляем снимок статистики и спарклайн задержки
+func (m *tuiModel) refresh() {
+	m.snapshot = m.live.snapshot()
+	if m.snapshot.IntervalOK {
+		m.latencies = append(m.latencies, m.snapshot.Interval)
+		if len(m.latencies) > sparklineWidth {
+			m.latencies = m.latencies[len(m.latencies)-sparklineWidth:]
+		}
+	}
+}
+
+// Обрабатываем нажатие клавиши; возвращает true, если нужно выйти
+func (m *tuiModel) handleKey(key string) bool {
+	switch key {
+	case "q", keyCtrlC, keyEsc:
+		return true
+	case keyRight, "\t":
+		m.view = (m.view + 1) % len(tuiViews)
+	case keyLeft:
+		m.view = (m.view + len(tuiViews) - 1) % len(tuiViews)
+	}
+	return false
+}
+
+// Текст экрана. В raw режиме терминала перевод строки не возвращает каретку,
+// поэтому строки разделяются "\r\n"
+func (m *tuiModel) render() string {
+	var b strings.Builder
+	s := m.snapshot
+
+	status := "обработка..."
+	if m.done {
+		status = "готово"
+	}
+	fmt.Fprintf(&b, "%s — %s\n\n", m.title, status)
+	fmt.Fprintf(&b, "Запросов: %s   Ошибок: %s (%.1f%%)   Среднее время ответа: %.2f ms\n",
+		formatCount(s.Total), formatCount(s.Errors), percent(s.Errors, s.Total), s.AvgTime)
+	fmt.Fprintf(&b, "Задержка: %s\n\n", sparkline(m.latencies))
+
+	// Вкладки: текущая выделена скобками
+	for i, name := range tuiViews {
+		if i == m.view {
+			fmt.Fprintf(&b, "[%s] ", name)
+		} else {
+			fmt.Fprintf(&b, " %s  ", name)
+		}
+	}
+	b.WriteString("\n")
+
+	rows := [][]keyCount{s.TopIPs, s.TopURLs, s.Methods}[m.view]
+	for _, row := range rows {
+		fmt.Fprintf(&b, "  %-40s %12s\n", row.key, formatCount(row.count))
+	}
+
+	b.WriteString("\n←/→ — переключить таблицу, q — выход\n")
+	return strings.ReplaceAll(b.String(), "\n", "\r\n")
+}
+
+// Символы спарклайна от наименьшего значения к наибольшему
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Спарклайн: каждое значение — символ, высота которого пропорциональна значению
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = min(low, v), max(high, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if high > low {
+			level = int((v - low) / (high - low) * float64(len(sparkLevels)-1))
+		}
+		b.WriteRune(sparkLevels[level])
+	}
+	return fmt.Sprintf("%s  (%.0f–%.0f ms)", b.String(), low, high)
+}
+
+// Управляющие последовательности терминала
+const (
+	ansiEnterScreen = "\x1b[?1049h\x1b[?25l" // альтернативный экран, курсор скрыт
+	ansiLeaveScreen = "\x1b[?25h\x1b[?1049l" // возврат к обычному экрану
+	ansiClear       = "\x1b[H\x1b[2J"        // курсор в начало, очистка экрана
+)
+
+// Клавиши, которые приходят последовательностями байтов
+const (
+	keyCtrlC = "\x03"
+	keyEsc   = "\x1b"
+	keyRight = "\x1b[C"
+	keyLeft  = "\x1b[D"
+)
+
+// Читаем нажатия клавиш из in (терминал в raw режиме). Горутина чтения живет
+// до конца программы: прервать блокирующее чтение stdin нельзя.
+func readKeys(in *os.File) <-chan string {
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buf[:n])
+		}
+	}()
+	return keys
+}
+
+// Запускаем pipeline под управлением TUI: экран обновляется, пока идет обработка,
+// и остается открытым после ее окончания до нажатия q. Выход до окончания
+// отменяет контекст, и runPipeline возвращает частичную статистику.
+// Для TUI stdin и stdout должны быть терминалом.
+func runPipelineWithTUI(ctx context.Context, title string, live *liveStats, run func(ctx context.Context) (Statistics, error)) (Statistics, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return Statistics{}, errors.New("для --tui stdin и stdout должны быть терминалом")
+	}
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return Statistics{}, fmt.Errorf("ошибка перевода терминала в raw режим: %w", err)
+	}
+	fmt.Print(ansiEnterScreen)
+	defer func() {
+		fmt.Print(ansiLeaveScreen)
+		term.Restore(int(os.Stdin.Fd()), oldState)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stats Statistics
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		stats, err = run(ctx)
+	}()
+
+	model := &tuiModel{title: title, live: live}
+	keys := readKeys(os.Stdin)
+	ticker := time.NewTicker(tuiRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		model.refresh()
+		fmt.Print(ansiClear + model.render())
+
+		select {
+		case key, ok := <-keys:
+			if !ok || model.handleKey(key) {
+				// Выход до конца обработки останавливает pipeline (как Ctrl+C без TUI)
+				cancel()
+				if !model.done {
+					<-finished
+				}
+				return stats, err
+			}
+		case <-ticker.C:
+		case <-finished:
+			model.done = true
+			finished = nil // закрытый канал больше не ждем
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// Одновременные обновления счетчиков не теряются
+func TestLiveCountersConcurrent(t *testing.T) {
+	var counters liveCounters
+	isError := errorFilter(defaultOptions())
+	entries := []LogEntry{{StatusCode: 200}, {StatusCode: 404}, {StatusCode: 503}, {StatusCode: 99}}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				counters.add(entries[i%len(entries)], isError)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := counters.snapshot()
+	if s.Total != 8000 || s.Errors != 4000 {
+		t.Errorf("Total, Errors = %d, %d, ожидалось 8000, 4000", s.Total, s.Errors)
+	}
+	want := map[string]int64{"2xx": 2000, "4xx": 2000, "5xx": 2000, "other": 2000}
+	for class, count := range want {
+		if s.ByClass[class] != count {
+			t.Errorf("ByClass[%s] = %d, ожидалось %d", class, s.ByClass[class], count)
+		}
+	}
+}
+
+// Атомарные счетчики против накопителя под мьютексом при одновременных обновлениях
+// (как при записи из pipeline и чтении из /counters): go test -bench LiveCounters -cpu 1,4,8
+func BenchmarkLiveCountersAtomic(b *testing.B) {
+	var counters liveCounters
+	isError := errorFilter(defaultOptions())
+	logEntry := LogEntry{StatusCode: 500}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			counters.add(logEntry, isError)
+		}
+	})
+}
+
+func BenchmarkLiveCountersMutex(b *testing.B) {
+	opts := defaultOptions()
+	opts.Stats = statTotal | statErrors | statStatusClasses
+	var mu sync.Mutex
+	acc := newStatsAccumulator(opts)
+	logEntry := LogEntry{StatusCode: 500}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.Lock()
+			acc.Add(logEntry)
+			mu.Unlock()
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Сторож зависания pipeline (--stall-timeout): считает записи, дошедшие до конца
+// pipeline, и если за timeout не прошло ни одной, выводит предупреждение со стеками
+// всех горутин — по ним видно, какая стадия ждет и чего. Счетчик атомарный, поэтому
+// стадии обходится в одно сложение на запись.
+//
+// Предупреждение выводится один раз на каждое зависание; когда записи снова пошли,
+// об этом тоже сообщается. Для Kafka и сокетов затишье во входных данных выглядит так же,
+// как зависание, поэтому timeout для них стоит брать больше обычной паузы между записями.
+type stallWatchdog struct {
+	timeout   time.Duration
+	processed atomic.Int64
+	finished  atomic.Bool // все записи обработаны, следить больше не за чем
+}
+
+// Стадия pipeline: учитываем каждую запись и передаем дальше без изменений.
+// Когда входной канал закрыт, обработка закончена и сторож перестает проверять.
+func (w *stallWatchdog) count(input <-chan LogEntry) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+		defer w.finished.Store(true)
+		for logEntry := range input {
+			w.processed.Add(1)
+			out <- logEntry
+		}
+	}()
+
+	return out
+}
+
+// Проверяем счетчик раз в timeout и сообщаем в out о зависании (зависанием считается
+// отсутствие новых записей от timeout до двух timeout). stop завершает проверку;
+// повторные вызовы ничего не делают.
+func (w *stallWatchdog) start(out io.Writer) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(w.timeout)
+		defer ticker.Stop()
+
+		last := w.processed.Load()
+		stalled := false
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+			if w.finished.Load() {
+				return
+			}
+			current := w.processed.Load()
+			switch {
+			case current != last && stalled:
+				fmt.Fprintf(out, "обработка продолжилась: обработано записей: %s\n", formatCount(int(current)))
+				stalled = false
+			case current == last && !stalled:
+				fmt.Fprintf(out, "предупреждение: за %v не обработано ни одной записи (всего обработано: %s), возможно, pipeline завис; стеки горутин:\n%s\n",
+					w.timeout, formatCount(int(current)), goroutineStacks())
+				stalled = true
+			}
+			last = current
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
+
+// Стеки всех горутин (как при панике), буфер растет, пока стеки не поместятся
+func goroutineStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}